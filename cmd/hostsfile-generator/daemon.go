@@ -4,9 +4,13 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 
 	"github.com/Eagerod/hostsfile-generator/pkg/daemon"
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
 )
 
 var VersionBuild string = "unstable-dev"
@@ -14,6 +18,78 @@ var VersionBuild string = "unstable-dev"
 func Run() error {
 	ip := flag.String("ingress-ip", "", "IP address of the NGINX Ingress Controller.")
 	searchDomain := flag.String("search-domain", "", "Search domain to append to bare hostnames.")
+	nodeHostnames := flag.Bool("node-hostnames", false, "Publish node names with their InternalIP addresses.")
+	nodeExternalIps := flag.Bool("node-external-ips", false, "Also publish node ExternalIP addresses when publishing node names.")
+	podHostnames := flag.Bool("pod-hostnames", false, "Publish ready pods annotated with "+daemon.PodHostnameAnnotation+" to their pod IPs.")
+	hostsRecords := flag.Bool("hosts-records", false, "Publish HostsRecord custom resources. Requires the HostsRecord CRD to be installed.")
+	dnsEndpoints := flag.Bool("dns-endpoints", false, "Publish A and AAAA records from external-dns DNSEndpoint resources.")
+	staticHostsFile := flag.String("static-hosts-file", "", "Path to a hosts-format file to merge into the output.")
+	staticHostsConfigMap := flag.String("static-hosts-configmap", "", "ConfigMap (namespace/name) holding hosts-format entries to merge into the output.")
+	staticHostsConfigMapKey := flag.String("static-hosts-configmap-key", "hosts", "Key of the static hosts ConfigMap holding the entries.")
+	wildcards := flag.String("wildcards", "skip", "What to do with wildcard hostnames (skip, allow). Hosts format output never includes them.")
+	conflicts := flag.String("conflicts", "oldest", "Which object keeps a hostname claimed with different records (oldest, priority, drop).")
+	collapseByIp := flag.Bool("collapse-by-ip", false, "Write a single line per IP with every hostname published to it.")
+	maxHostsPerLine := flag.Int("max-hosts-per-line", 0, "Split collapsed lines with more hostnames than this. 0 for no limit.")
+	maxLineLength := flag.Int("max-line-length", 0, "Split collapsed lines longer than this. 0 for no limit.")
+	provenance := flag.Bool("provenance", false, "Add comments naming the objects each line came from, and a generated-by header.")
+	format := flag.String("format", "hosts", "Format written to the Pi-hole pod, or stdout ("+strings.Join(hostsfile.RendererFormats, ", ")+").")
+	templateFile := flag.String("template", "", "Path to a text/template file used by the template format.")
+	ptrRecords := flag.Bool("ptr-records", false, "Add ptr-record lines to dnsmasq-address output.")
+	reverseZone := flag.String("reverse-zone", "", "in-addr.arpa or ip6.arpa domain of the reverse-zone format.")
+	zoneNameservers := flag.String("zone-nameservers", "", "Comma separated nameservers for the NS records of the zone format. The first is used in the SOA record.")
+	zoneHostmaster := flag.String("zone-hostmaster", "", "Email address for the SOA record of the zone format. Defaults to hostmaster in the search domain.")
+	zoneTtl := flag.Uint("zone-ttl", 300, "TTL of the records in the zone format.")
+	zoneSerialFile := flag.String("zone-serial-file", "", "Path to keep the zone format's SOA serial in, so it keeps increasing across restarts.")
+	noPihole := flag.Bool("no-pihole", false, "Don't write to the Pi-hole pod, or stdout.")
+	dnsListen := flag.String("dns-listen", "", "Address to answer DNS queries for the search domain on, over UDP and TCP (e.g. :53).")
+	dnsUpstream := flag.String("dns-upstream", "", "Server to forward DNS queries outside of the search domain to (e.g. 1.1.1.1:53). Refused if not set.")
+	dnsTtl := flag.Uint("dns-ttl", 60, "TTL of the records answered by the DNS server.")
+	dnsSecondaries := flag.String("dns-secondaries", "", "Comma separated secondaries to NOTIFY when the zone changes, and to allow transfers from.")
+	dnsAllowTransfer := flag.String("dns-allow-transfer", "", "Comma separated networks (e.g. 10.0.0.0/8) to also allow zone transfers from.")
+	dnsUpdateServer := flag.String("dns-update-server", "", "Primary to keep the search domain's zone up to date on with RFC 2136 dynamic updates (e.g. 192.168.200.10:53).")
+	dnsUpdateTtl := flag.Uint("dns-update-ttl", 300, "TTL of the records sent in dynamic updates.")
+	dnsUpdateStateFile := flag.String("dns-update-state-file", "", "Path to keep track of the records the daemon created with dynamic updates in, so they're cleaned up across restarts.")
+	tsigKeyName := flag.String("tsig-key-name", "", "Name of the TSIG key to sign dynamic updates with.")
+	tsigSecretFile := flag.String("tsig-secret-file", "", "Path to a file holding the base64 TSIG secret.")
+	tsigAlgorithm := flag.String("tsig-algorithm", "hmac-sha256", "Algorithm of the TSIG key.")
+	piholeApiUrl := flag.String("pihole-api-url", "", "Pi-hole web interface (e.g. http://pi.hole) to manage local DNS and CNAME records through.")
+	piholeApiPasswordFile := flag.String("pihole-api-password-file", "", "Path to a file holding the Pi-hole's web or app password.")
+	piholeApiStateFile := flag.String("pihole-api-state-file", "", "Path to keep track of the Pi-hole records the daemon created in, so they're cleaned up across restarts.")
+	adguardUrl := flag.String("adguard-url", "", "AdGuard Home web interface (e.g. http://adguard.internal) to manage DNS rewrites through.")
+	adguardUsername := flag.String("adguard-username", "", "Username to sign in to AdGuard Home with.")
+	adguardPasswordFile := flag.String("adguard-password-file", "", "Path to a file holding the AdGuard Home password.")
+	adguardStateFile := flag.String("adguard-state-file", "", "Path to keep track of the AdGuard Home rewrites the daemon created in, so they're cleaned up across restarts.")
+	powerdnsUrl := flag.String("powerdns-url", "", "PowerDNS API (e.g. http://powerdns.internal:8081) to manage the search domain's zone through.")
+	powerdnsApiKeyFile := flag.String("powerdns-api-key-file", "", "Path to a file holding the PowerDNS API key.")
+	powerdnsServerId := flag.String("powerdns-server-id", "localhost", "PowerDNS server the zone lives on.")
+	powerdnsTtl := flag.Uint("powerdns-ttl", 300, "TTL of the records sent to PowerDNS.")
+	powerdnsStateFile := flag.String("powerdns-state-file", "", "Path to keep track of the PowerDNS RRsets the daemon created in, so they're cleaned up across restarts.")
+	restAddUrl := flag.String("rest-add-url", "", "URL template of the request that adds a record, for APIs without a dedicated sink.")
+	restAddMethod := flag.String("rest-add-method", "POST", "Method of the request that adds a record.")
+	restAddBody := flag.String("rest-add-body", "", "Body template of the request that adds a record.")
+	restRemoveUrl := flag.String("rest-remove-url", "", "URL template of the request that removes a record.")
+	restRemoveMethod := flag.String("rest-remove-method", "DELETE", "Method of the request that removes a record.")
+	restRemoveBody := flag.String("rest-remove-body", "", "Body template of the request that removes a record.")
+	restHeaders := headersFlag{}
+	flag.Var(&restHeaders, "rest-header", "Header template (Name: value) sent with every record request. Can be given more than once.")
+	restTokenFile := flag.String("rest-token-file", "", "Path to a file holding the token available to record request templates.")
+	restTtl := flag.Uint("rest-ttl", 300, "TTL of the records sent in record requests.")
+	restStateFile := flag.String("rest-state-file", "", "Path to keep track of the records that were added in, so they're cleaned up across restarts. Required with --rest-add-url.")
+	unboundControl := flag.String("unbound-control", "", "Unbound remote control interface (e.g. 127.0.0.1:8953), or control socket path, to apply changes to its local data through.")
+	unboundServerCert := flag.String("unbound-server-cert", "", "Path to Unbound's server certificate (unbound_server.pem).")
+	unboundControlCert := flag.String("unbound-control-cert", "", "Path to Unbound's control certificate (unbound_control.pem).")
+	unboundControlKey := flag.String("unbound-control-key", "", "Path to Unbound's control key (unbound_control.key).")
+	unboundTtl := flag.Uint("unbound-ttl", 300, "TTL of the local data sent to Unbound.")
+	unboundStateFile := flag.String("unbound-state-file", "", "Path to keep track of the Unbound local data the daemon created in, so it's cleaned up across restarts.")
+	hostsFile := flag.String("hosts-file", "", "Hosts file (e.g. /etc/hosts) to keep a managed block of entries up to date in.")
+	hostsFileFormat := flag.String("hosts-file-format", "hosts", "Format of the block written to the hosts file ("+strings.Join(hostsfile.RendererFormats, ", ")+").")
+	webhookUrl := flag.String("webhook-url", "", "URL to POST the published records to whenever they change.")
+	webhookSecretFile := flag.String("webhook-secret-file", "", "Path to a file holding the secret to sign webhook bodies with.")
+	webhookDiff := flag.Bool("webhook-diff", false, "POST only the records added and removed since the last webhook, rather than all of them.")
+	webhookRetries := flag.Int("webhook-retries", 3, "How many times to retry a failed webhook.")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig to use when running outside of the cluster. Defaults to KUBECONFIG, then ~/.kube/config.")
+	kubeContext := flag.String("context", "", "Kubeconfig context to use. Defaults to the current context.")
+	insecureServerIp := flag.Bool("insecure-server-ip", false, "Allow connecting to SERVER_IP without verifying the API server's certificate.")
 	version := flag.Bool("v", false, "Print the version and exit.")
 
 	flag.Parse()
@@ -28,21 +104,320 @@ func Run() error {
 		return errors.New("Invalid configuration")
 	}
 
-	// If running in the cluster, pull the service account token, else, pull
-	//   the values from an environment variable.
-	daemonConfig, err := daemon.NewDaemonConfigInCluster(*ip, *searchDomain)
+	wildcardPolicy, err := hostsfile.ParseWildcardPolicy(*wildcards)
 	if err != nil {
-		serverIp := os.Getenv("SERVER_IP")
-		sat := os.Getenv("SERVICE_ACCOUNT_TOKEN")
-		piholePodName := os.Getenv("PIHOLE_POD_NAME")
+		flag.Usage()
+		return err
+	}
 
-		daemonConfig, err = daemon.NewDaemonConfig(*ip, *searchDomain, serverIp, sat, piholePodName)
+	conflictPolicy, err := hostsfile.ParseConflictPolicy(*conflicts)
+	if err != nil {
+		flag.Usage()
+		return err
+	}
+
+	renderOptions := hostsfile.RenderOptions{
+		CollapseByIp:    *collapseByIp,
+		MaxHostsPerLine: *maxHostsPerLine,
+		MaxLineLength:   *maxLineLength,
+		Provenance:      *provenance,
+		Version:         VersionBuild,
+		PtrRecords:      *ptrRecords,
+		Zone: hostsfile.ZoneOptions{
+			Origin:        *searchDomain,
+			ReverseOrigin: *reverseZone,
+			Hostmaster:    *zoneHostmaster,
+			Ttl:           uint32(*zoneTtl),
+			SerialFile:    *zoneSerialFile,
+		},
+	}
+	if *zoneNameservers != "" {
+		renderOptions.Zone.Nameservers = strings.Split(*zoneNameservers, ",")
+	}
+	if *templateFile != "" {
+		contents, err := os.ReadFile(*templateFile)
 		if err != nil {
 			return err
 		}
+		renderOptions.Template = string(contents)
+	}
+
+	renderer, err := hostsfile.NewRenderer(*format, renderOptions)
+	if err != nil {
+		flag.Usage()
+		return err
+	}
+
+	staticConfigMapNamespace, staticConfigMapName := "", ""
+	if *staticHostsConfigMap != "" {
+		parts := strings.Split(*staticHostsConfigMap, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			flag.Usage()
+			return errors.New("Invalid configuration")
+		}
+		staticConfigMapNamespace, staticConfigMapName = parts[0], parts[1]
+	}
+
+	// An explicit kubeconfig or context always wins. Otherwise, if running in
+	//   the cluster, pull the service account token, else, fall back to the
+	//   older environment variables, if explicitly allowed, and then to
+	//   kubectl's kubeconfig.
+	piholePodName := os.Getenv("PIHOLE_POD_NAME")
+	var daemonConfig *daemon.DaemonConfig
+	if *kubeconfig != "" || *kubeContext != "" {
+		daemonConfig, err = daemon.NewDaemonConfigFromKubeconfig(*ip, *searchDomain, *kubeconfig, *kubeContext, piholePodName)
+	} else if daemonConfig, err = daemon.NewDaemonConfigInCluster(*ip, *searchDomain); err != nil {
+		if serverIp := os.Getenv("SERVER_IP"); serverIp != "" {
+			if !*insecureServerIp {
+				flag.Usage()
+				return errors.New("SERVER_IP doesn't verify the API server's certificate; use --kubeconfig, or allow it with --insecure-server-ip")
+			}
+
+			log.Println("SERVER_IP is set; the API server's certificate won't be verified. Use --kubeconfig instead.")
+			sat := os.Getenv("SERVICE_ACCOUNT_TOKEN")
+			daemonConfig, err = daemon.NewDaemonConfig(*ip, *searchDomain, serverIp, sat, piholePodName)
+		} else {
+			daemonConfig, err = daemon.NewDaemonConfigFromKubeconfig(*ip, *searchDomain, "", "", piholePodName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	daemonConfig.WildcardPolicy = wildcardPolicy
+	daemonConfig.ConflictPolicy = conflictPolicy
+	daemonConfig.Renderer = renderer
+	daemonConfig.NodeHostnames = *nodeHostnames
+	daemonConfig.NodeExternalIps = *nodeExternalIps
+	daemonConfig.PodHostnames = *podHostnames
+	daemonConfig.HostsRecords = *hostsRecords
+	daemonConfig.DNSEndpoints = *dnsEndpoints
+	daemonConfig.StaticHostsFile = *staticHostsFile
+	daemonConfig.StaticHostsConfigMapNamespace = staticConfigMapNamespace
+	daemonConfig.StaticHostsConfigMapName = staticConfigMapName
+	daemonConfig.StaticHostsConfigMapKey = *staticHostsConfigMapKey
+	daemonConfig.DisablePihole = *noPihole
+
+	if *dnsListen != "" {
+		serverOptions := dnsserver.ServerOptions{
+			Zone:        *searchDomain,
+			Nameservers: renderOptions.Zone.Nameservers,
+			Hostmaster:  *zoneHostmaster,
+			Ttl:         uint32(*dnsTtl),
+			Upstream:    *dnsUpstream,
+		}
+		if *dnsSecondaries != "" {
+			serverOptions.Secondaries = strings.Split(*dnsSecondaries, ",")
+		}
+		if *dnsAllowTransfer != "" {
+			serverOptions.AllowTransfer = strings.Split(*dnsAllowTransfer, ",")
+		}
+
+		server, err := dnsserver.NewServer(serverOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		if err := server.Listen(*dnsListen); err != nil {
+			return err
+		}
+		defer server.Shutdown()
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, server)
+	}
+
+	if *dnsUpdateServer != "" {
+		updateOptions := daemon.DynamicUpdateOptions{
+			Server:        *dnsUpdateServer,
+			Zone:          *searchDomain,
+			Ttl:           uint32(*dnsUpdateTtl),
+			StateFile:     *dnsUpdateStateFile,
+			TsigName:      *tsigKeyName,
+			TsigAlgorithm: *tsigAlgorithm,
+		}
+		if *tsigSecretFile != "" {
+			contents, err := os.ReadFile(*tsigSecretFile)
+			if err != nil {
+				return err
+			}
+			updateOptions.TsigSecret = strings.TrimSpace(string(contents))
+		}
+
+		sink, err := daemon.NewDaemonDynamicUpdateSink(updateOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *piholeApiUrl != "" {
+		piholeOptions := daemon.PiholeAPIOptions{
+			Url:       *piholeApiUrl,
+			StateFile: *piholeApiStateFile,
+		}
+		if *piholeApiPasswordFile != "" {
+			contents, err := os.ReadFile(*piholeApiPasswordFile)
+			if err != nil {
+				return err
+			}
+			piholeOptions.Password = strings.TrimSpace(string(contents))
+		}
+
+		sink, err := daemon.NewDaemonPiholeAPISink(piholeOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *adguardUrl != "" {
+		adguardOptions := daemon.AdGuardOptions{
+			Url:       *adguardUrl,
+			Username:  *adguardUsername,
+			StateFile: *adguardStateFile,
+		}
+		if *adguardPasswordFile != "" {
+			contents, err := os.ReadFile(*adguardPasswordFile)
+			if err != nil {
+				return err
+			}
+			adguardOptions.Password = strings.TrimSpace(string(contents))
+		}
+
+		sink, err := daemon.NewDaemonAdGuardSink(adguardOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *powerdnsUrl != "" {
+		powerdnsOptions := daemon.PowerDNSOptions{
+			Url:       *powerdnsUrl,
+			ServerId:  *powerdnsServerId,
+			Zone:      *searchDomain,
+			Ttl:       uint32(*powerdnsTtl),
+			StateFile: *powerdnsStateFile,
+		}
+		if *powerdnsApiKeyFile != "" {
+			contents, err := os.ReadFile(*powerdnsApiKeyFile)
+			if err != nil {
+				return err
+			}
+			powerdnsOptions.ApiKey = strings.TrimSpace(string(contents))
+		}
+
+		sink, err := daemon.NewDaemonPowerDNSSink(powerdnsOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *restAddUrl != "" || *restRemoveUrl != "" {
+		sink, err := daemon.NewDaemonRESTSink(daemon.RESTOptions{
+			Add:       daemon.RESTRequest{Method: *restAddMethod, Url: *restAddUrl, Body: *restAddBody},
+			Remove:    daemon.RESTRequest{Method: *restRemoveMethod, Url: *restRemoveUrl, Body: *restRemoveBody},
+			Headers:   restHeaders,
+			TokenFile: *restTokenFile,
+			Zone:      *searchDomain,
+			Ttl:       uint32(*restTtl),
+			StateFile: *restStateFile,
+		})
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *unboundControl != "" {
+		sink, err := daemon.NewDaemonUnboundSink(daemon.UnboundOptions{
+			Address:         *unboundControl,
+			ServerCertFile:  *unboundServerCert,
+			ControlCertFile: *unboundControlCert,
+			ControlKeyFile:  *unboundControlKey,
+			Zone:            *searchDomain,
+			Ttl:             uint32(*unboundTtl),
+			StateFile:       *unboundStateFile,
+		})
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
+	}
+
+	if *hostsFile != "" {
+		hostsRenderer, err := hostsfile.NewRenderer(*hostsFileFormat, renderOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, daemon.NewDaemonHostsFileSink(*hostsFile, hostsRenderer))
+	}
+
+	if *webhookUrl != "" {
+		webhookOptions := daemon.WebhookOptions{
+			Url:     *webhookUrl,
+			Diff:    *webhookDiff,
+			Version: VersionBuild,
+			Retries: *webhookRetries,
+		}
+		if *webhookSecretFile != "" {
+			contents, err := os.ReadFile(*webhookSecretFile)
+			if err != nil {
+				return err
+			}
+			webhookOptions.Secret = strings.TrimSpace(string(contents))
+		}
+
+		sink, err := daemon.NewDaemonWebhookSink(webhookOptions)
+		if err != nil {
+			flag.Usage()
+			return err
+		}
+
+		daemonConfig.Sinks = append(daemonConfig.Sinks, sink)
 	}
 
 	d := daemon.NewHostsFileDaemon(*daemonConfig)
 	d.Run()
 	return nil
 }
+
+// Collects "Name: value" headers from a flag that can be given more than
+// once.
+type headersFlag map[string]string
+
+func (h headersFlag) String() string {
+	headers := []string{}
+	for name, value := range h {
+		headers = append(headers, name+": "+value)
+	}
+
+	return strings.Join(headers, ", ")
+}
+
+func (h headersFlag) Set(header string) error {
+	parts := strings.SplitN(header, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("invalid header %s", header)
+	}
+
+	h[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	return nil
+}
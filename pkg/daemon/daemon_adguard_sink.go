@@ -0,0 +1,185 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Url is AdGuard Home's web interface, e.g. http://adguard.internal.
+// Username and Password are used for basic auth, if given.
+// AdGuard Home doesn't say who created a rewrite, so after a restart, only
+// those listed in StateFile are known to be the sink's to remove.
+type AdGuardOptions struct {
+	Url       string
+	Username  string
+	Password  string
+	StateFile string
+}
+
+// Reconciles AdGuard Home's DNS rewrites through its /control/rewrite API.
+// Rewrites that are missing are added, and rewrites that the sink added that
+// are no longer published are removed; rewrites created some other way are
+// never touched. Wildcards and CNAME records are published as rewrites too;
+// SRV and TXT records can't be, so they're left out.
+type DaemonAdGuardSink struct {
+	options AdGuardOptions
+	client  *http.Client
+	owned   *ownedRecords
+}
+
+type adGuardRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+func NewDaemonAdGuardSink(options AdGuardOptions) (*DaemonAdGuardSink, error) {
+	if options.Url == "" {
+		return nil, errors.New("adguard home requires a url")
+	}
+	options.Url = strings.TrimSuffix(options.Url, "/")
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DaemonAdGuardSink{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		owned:   owned,
+	}
+	return &d, nil
+}
+
+func (d *DaemonAdGuardSink) Name() string {
+	return fmt.Sprintf("adguard home at %s", d.options.Url)
+}
+
+func (d *DaemonAdGuardSink) Update(snapshot hostsfile.Snapshot) error {
+	rewrites := []adGuardRewrite{}
+	if err := d.request(http.MethodGet, "/control/rewrite/list", nil, &rewrites); err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, rewrite := range rewrites {
+		existing[rewrite.key()] = true
+	}
+
+	wanted := map[string]bool{}
+	for _, rewrite := range adGuardRewrites(snapshot) {
+		wanted[rewrite.key()] = true
+		if existing[rewrite.key()] {
+			continue
+		}
+
+		if err := d.request(http.MethodPost, "/control/rewrite/add", rewrite, nil); err != nil {
+			return err
+		}
+		d.owned.Add(rewrite.key())
+	}
+
+	for _, key := range d.owned.Keys() {
+		if wanted[key] {
+			continue
+		}
+
+		if existing[key] {
+			parts := strings.SplitN(key, " ", 2)
+			if err := d.request(http.MethodPost, "/control/rewrite/delete", adGuardRewrite{parts[0], parts[1]}, nil); err != nil {
+				return err
+			}
+		}
+		d.owned.Remove(key)
+	}
+
+	return d.owned.Save()
+}
+
+func (d *DaemonAdGuardSink) request(method, path string, body interface{}, response interface{}) error {
+	var contents []byte
+	if body != nil {
+		var err error
+		if contents, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, d.options.Url+path, bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if d.options.Username != "" || d.options.Password != "" {
+		req.SetBasicAuth(d.options.Username, d.options.Password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if message := strings.TrimSpace(string(contents)); message != "" {
+			return fmt.Errorf("adguard home %s %s failed: %s: %s", method, path, resp.Status, message)
+		}
+		return fmt.Errorf("adguard home %s %s failed: %s", method, path, resp.Status)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(contents, response)
+}
+
+func (r adGuardRewrite) key() string {
+	return fmt.Sprintf("%s %s", r.Domain, r.Answer)
+}
+
+// Addresses, and CNAME targets, are both given as the rewrite's answer.
+func adGuardRewrites(snapshot hostsfile.Snapshot) []adGuardRewrite {
+	rewrites := []adGuardRewrite{}
+	seen := map[string]bool{}
+	add := func(rewrite adGuardRewrite) {
+		if !seen[rewrite.key()] {
+			seen[rewrite.key()] = true
+			rewrites = append(rewrites, rewrite)
+		}
+	}
+
+	for _, group := range snapshot.Groups {
+		for _, entry := range group.Entries {
+			for _, host := range entry.Hosts() {
+				add(adGuardRewrite{bareHostname(host), entry.Ip()})
+			}
+		}
+
+		for _, record := range group.Records {
+			if record.Type() == hostsfile.RecordCNAME {
+				add(adGuardRewrite{bareHostname(record.Name()), bareHostname(record.Target())})
+			}
+		}
+	}
+
+	return rewrites
+}
@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Enough of AdGuard Home's API to manage rewrites, behind basic auth.
+type testAdGuardAPI struct {
+	lock     sync.Mutex
+	rewrites []adGuardRewrite
+	deletes  int
+}
+
+func (a *testAdGuardAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "hunter2" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Forbidden\n"))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/control/rewrite/list":
+		json.NewEncoder(w).Encode(a.rewrites)
+	case "/control/rewrite/add":
+		rewrite := adGuardRewrite{}
+		json.NewDecoder(r.Body).Decode(&rewrite)
+		a.rewrites = append(a.rewrites, rewrite)
+	case "/control/rewrite/delete":
+		rewrite := adGuardRewrite{}
+		json.NewDecoder(r.Body).Decode(&rewrite)
+		rewrites := []adGuardRewrite{}
+		for _, existing := range a.rewrites {
+			if existing != rewrite {
+				rewrites = append(rewrites, existing)
+			}
+		}
+		a.rewrites = rewrites
+		a.deletes++
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testAdGuardServer(t *testing.T) (*testAdGuardAPI, string) {
+	a := &testAdGuardAPI{
+		rewrites: []adGuardRewrite{{"router.internal.aleemhaji.com", "192.168.1.1"}},
+	}
+
+	server := httptest.NewServer(a)
+	t.Cleanup(server.Close)
+
+	return a, server.URL
+}
+
+func TestNewDaemonAdGuardSink(t *testing.T) {
+	_, err := NewDaemonAdGuardSink(AdGuardOptions{})
+	assert.Equal(t, "adguard home requires a url", err.Error())
+
+	d, err := NewDaemonAdGuardSink(AdGuardOptions{Url: "http://adguard.internal/"})
+	assert.Nil(t, err)
+	assert.Equal(t, "adguard home at http://adguard.internal", d.Name())
+}
+
+func TestDaemonAdGuardSinkUpdate(t *testing.T) {
+	a, serverUrl := testAdGuardServer(t)
+	stateFile := filepath.Join(t.TempDir(), "owned.json")
+
+	d, err := NewDaemonAdGuardSink(AdGuardOptions{Url: serverUrl, Username: "admin", Password: "hunter2", StateFile: stateFile})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com.", "*.apps.internal.aleemhaji.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.1", []string{"router.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewTXTRecord("some-service.internal.aleemhaji.com.", []string{"v=spf1 -all"}),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []adGuardRewrite{
+		{"router.internal.aleemhaji.com", "192.168.1.1"},
+		{"some-service.internal.aleemhaji.com", "192.168.1.2"},
+		{"*.apps.internal.aleemhaji.com", "192.168.1.2"},
+		{"www.internal.aleemhaji.com", "some-service.internal.aleemhaji.com"},
+	}, a.rewrites)
+
+	// Nothing changed, so nothing is touched.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 4, len(a.rewrites))
+	assert.Equal(t, 0, a.deletes)
+
+	// The router's rewrite was already there, so it's never removed.
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []adGuardRewrite{
+		{"router.internal.aleemhaji.com", "192.168.1.1"},
+		{"some-service.internal.aleemhaji.com", "192.168.1.3"},
+	}, a.rewrites)
+
+	// Ownership is picked up again after a restart.
+	d, err = NewDaemonAdGuardSink(AdGuardOptions{Url: serverUrl, Username: "admin", Password: "hunter2", StateFile: stateFile})
+	assert.Nil(t, err)
+	assert.Nil(t, d.Update(hostsfile.Snapshot{}))
+	assert.Equal(t, []adGuardRewrite{{"router.internal.aleemhaji.com", "192.168.1.1"}}, a.rewrites)
+}
+
+func TestDaemonAdGuardSinkUpdateForbidden(t *testing.T) {
+	_, serverUrl := testAdGuardServer(t)
+
+	d, err := NewDaemonAdGuardSink(AdGuardOptions{Url: serverUrl})
+	assert.Nil(t, err)
+
+	err = d.Update(hostsfile.Snapshot{})
+	assert.Equal(t, "adguard home GET /control/rewrite/list failed: 403 Forbidden: Forbidden", err.Error())
+}
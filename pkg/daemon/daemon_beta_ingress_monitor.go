@@ -45,7 +45,7 @@ func (d *DaemonBetaIngressMonitor) ValidateResource(obj interface{}) (string, er
 	return objectId, nil
 }
 
-func (d *DaemonBetaIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsfile.HostsEntry {
+func (d *DaemonBetaIngressMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
 	ingress, ok := obj.(*extensionsv1beta1.Ingress)
 	if !ok {
 		panic("Failed to get Ingress from pre-validated type.")
@@ -54,6 +54,11 @@ func (d *DaemonBetaIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsf
 	hostnames := []string{}
 
 	for _, rule := range ingress.Spec.Rules {
+		// Catch-all rules don't have a name to publish.
+		if rule.Host == "" {
+			continue
+		}
+
 		if strings.HasSuffix(rule.Host, d.searchDomain) {
 			hostnames = append(hostnames, rule.Host+".")
 		} else {
@@ -62,5 +67,5 @@ func (d *DaemonBetaIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsf
 	}
 
 	he := hostsfile.NewHostsEntry(d.ingressIp, hostnames)
-	return *he
+	return []hostsfile.HostsEntry{*he}
 }
@@ -71,14 +71,14 @@ func TestDaemonBetaIngressMonitorValidateResourceNotNginxIngress(t *testing.T) {
 	assert.Equal(t, "extensionsv1beta1.ingress/default/some-ingress", objectId)
 }
 
-func TestDaemonBetaIngressMonitorGetResourceHostsEntry(t *testing.T) {
+func TestDaemonBetaIngressMonitorGetResourceHostsEntries(t *testing.T) {
 	drm := DaemonBetaIngressMonitor{"192.168.1.1", "internal.aleemhaji.com"}
 
 	ingress := validTestBetaIngress()
 
 	e := hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress.internal.aleemhaji.com."})
-	he := drm.GetResourceHostsEntry(ingress)
-	assert.Equal(t, *e, he)
+	he := drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
 
 	ingress.Spec.Rules = []extensionsv1beta1.IngressRule{
 		extensionsv1beta1.IngressRule{
@@ -87,6 +87,17 @@ func TestDaemonBetaIngressMonitorGetResourceHostsEntry(t *testing.T) {
 	}
 
 	e = hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress"})
-	he = drm.GetResourceHostsEntry(ingress)
-	assert.Equal(t, *e, he)
+	he = drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
+}
+
+func TestDaemonBetaIngressMonitorGetResourceHostsEntriesCatchAll(t *testing.T) {
+	drm := DaemonBetaIngressMonitor{"192.168.1.1", "internal.aleemhaji.com"}
+
+	ingress := validTestBetaIngress()
+	ingress.Spec.Rules = append(ingress.Spec.Rules, extensionsv1beta1.IngressRule{})
+
+	e := hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
 }
@@ -5,18 +5,50 @@ import (
 	"os"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
 )
 
 // Everything needed to control what the daemon executes against.
 type DaemonConfig struct {
 	RestConfig          *rest.Config
 	KubernetesClientSet *kubernetes.Clientset
+	DynamicClient       dynamic.Interface
 
 	PiholePodName string
 	IngressIp     string
 	SearchDomain  string
+
+	WildcardPolicy hostsfile.WildcardPolicy
+	ConflictPolicy hostsfile.ConflictPolicy
+
+	// Format written to the Pi-hole pod, or stdout; hosts format if unset.
+	Renderer hostsfile.Renderer
+
+	// Sinks updated alongside the Pi-hole pod, unless it's disabled.
+	Sinks         []DaemonSink
+	DisablePihole bool
+
+	// Node and pod records are opt-in, since they require permission to list
+	// nodes and pods.
+	NodeHostnames   bool
+	NodeExternalIps bool
+	PodHostnames    bool
+
+	// Require the HostsRecord and external-dns DNSEndpoint CRDs to be
+	// installed respectively.
+	HostsRecords bool
+	DNSEndpoints bool
+
+	// Hosts-format entries that are merged in as they are.
+	StaticHostsFile               string
+	StaticHostsConfigMapNamespace string
+	StaticHostsConfigMapName      string
+	StaticHostsConfigMapKey       string
 }
 
 // Assumes that this is running in the same pod as the pihole.
@@ -31,20 +63,38 @@ func NewDaemonConfigInCluster(ingressIp string, searchDomain string) (*DaemonCon
 		return nil, err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
 	}
 
-	hostname, err := os.Hostname()
+	return newDaemonConfig(config, ingressIp, searchDomain, hostname)
+}
+
+// Loads the cluster and credentials the same way kubectl does: from the given
+// kubeconfig, or KUBECONFIG, or ~/.kube/config, using the given context, or
+// the current one. Certificates are verified, and client certificates and exec
+// credential plugins work as they do for kubectl.
+func NewDaemonConfigFromKubeconfig(ingressIp, searchDomain, kubeconfig, context, piholePodName string) (*DaemonConfig, error) {
+	if ingressIp == "" {
+		return nil, errors.New("ingress IP must be provided")
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+
+	overrides := clientcmd.ConfigOverrides{CurrentContext: context}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &overrides).ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	daemonConfig := DaemonConfig{config, clientset, hostname, ingressIp, searchDomain}
-	return &daemonConfig, nil
+	return newDaemonConfig(config, ingressIp, searchDomain, piholePodName)
 }
 
+// Doesn't verify the API server's certificate, since there's nothing to verify
+// it against; NewDaemonConfigFromKubeconfig should be preferred, and this only
+// used when whoever's running the daemon has asked for it.
 func NewDaemonConfig(ingressIp, searchDomain, clusterIp, bearerToken, piholePodName string) (*DaemonConfig, error) {
 	if ingressIp == "" {
 		return nil, errors.New("ingress IP must be provided")
@@ -71,11 +121,27 @@ func NewDaemonConfig(ingressIp, searchDomain, clusterIp, bearerToken, piholePodN
 	config.BearerToken = bearerToken
 	config.TLSClientConfig.Insecure = true
 
+	return newDaemonConfig(config, ingressIp, searchDomain, piholePodName)
+}
+
+func newDaemonConfig(config *rest.Config, ingressIp, searchDomain, piholePodName string) (*DaemonConfig, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	daemonConfig := DaemonConfig{config, clientset, piholePodName, ingressIp, searchDomain}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonConfig := DaemonConfig{
+		RestConfig:          config,
+		KubernetesClientSet: clientset,
+		DynamicClient:       dynamicClient,
+		PiholePodName:       piholePodName,
+		IngressIp:           ingressIp,
+		SearchDomain:        searchDomain,
+	}
 	return &daemonConfig, nil
 }
@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: home
+  cluster:
+    server: https://192.168.200.2:6443
+    certificate-authority: %[1]s/ca.crt
+- name: lab
+  cluster:
+    server: https://192.168.201.2:6443
+contexts:
+- name: home
+  context:
+    cluster: home
+    user: admin
+- name: lab
+  context:
+    cluster: lab
+    user: exec
+current-context: home
+users:
+- name: admin
+  user:
+    client-certificate: %[1]s/admin.crt
+    client-key: %[1]s/admin.key
+- name: exec
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: get-token
+      args: ["lab"]
+`
+
+// Writes a self-signed CA, and a client certificate and key it signed, as
+// ca.crt, admin.crt and admin.key. Nothing is ever dialed, so they only need
+// to parse.
+func writeTestClusterCertificates(t *testing.T, dir string) {
+	writePEM := func(name, blockType string, contents []byte) {
+		pemBlock := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: contents})
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), pemBlock, 0600))
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+
+	adminKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	adminTemplate := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "admin", Organization: []string{"system:masters"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	adminDer, err := x509.CreateCertificate(rand.Reader, &adminTemplate, &caTemplate, &adminKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	adminKeyDer, err := x509.MarshalECPrivateKey(adminKey)
+	assert.Nil(t, err)
+
+	writePEM("ca.crt", "CERTIFICATE", caDer)
+	writePEM("admin.crt", "CERTIFICATE", adminDer)
+	writePEM("admin.key", "EC PRIVATE KEY", adminKeyDer)
+}
+
+func writeTestKubeconfig(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	writeTestClusterCertificates(t, dir)
+
+	path := filepath.Join(dir, "config")
+	assert.Nil(t, os.WriteFile(path, []byte(fmt.Sprintf(testKubeconfig, dir)), 0600))
+
+	return dir, path
+}
+
+func TestNewDaemonConfigFromKubeconfig(t *testing.T) {
+	dir, path := writeTestKubeconfig(t)
+
+	config, err := NewDaemonConfigFromKubeconfig("192.168.200.128", "internal.aleemhaji.com", path, "", "pihole-0")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://192.168.200.2:6443", config.RestConfig.Host)
+	assert.False(t, config.RestConfig.TLSClientConfig.Insecure)
+	assert.Equal(t, filepath.Join(dir, "ca.crt"), config.RestConfig.TLSClientConfig.CAFile)
+	assert.Equal(t, filepath.Join(dir, "admin.crt"), config.RestConfig.TLSClientConfig.CertFile)
+	assert.Equal(t, filepath.Join(dir, "admin.key"), config.RestConfig.TLSClientConfig.KeyFile)
+	assert.Equal(t, "pihole-0", config.PiholePodName)
+	assert.Equal(t, "192.168.200.128", config.IngressIp)
+	assert.Equal(t, "internal.aleemhaji.com", config.SearchDomain)
+	assert.NotNil(t, config.KubernetesClientSet)
+	assert.NotNil(t, config.DynamicClient)
+}
+
+func TestNewDaemonConfigFromKubeconfigContext(t *testing.T) {
+	_, path := writeTestKubeconfig(t)
+
+	config, err := NewDaemonConfigFromKubeconfig("192.168.200.128", "internal.aleemhaji.com", path, "lab", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://192.168.201.2:6443", config.RestConfig.Host)
+	assert.False(t, config.RestConfig.TLSClientConfig.Insecure)
+	assert.Equal(t, "get-token", config.RestConfig.ExecProvider.Command)
+	assert.Equal(t, []string{"lab"}, config.RestConfig.ExecProvider.Args)
+
+	_, err = NewDaemonConfigFromKubeconfig("192.168.200.128", "internal.aleemhaji.com", path, "missing", "")
+	assert.Equal(t, "context \"missing\" does not exist", err.Error())
+}
+
+func TestNewDaemonConfigFromKubeconfigEnvironment(t *testing.T) {
+	_, path := writeTestKubeconfig(t)
+	t.Setenv("KUBECONFIG", path)
+
+	config, err := NewDaemonConfigFromKubeconfig("192.168.200.128", "internal.aleemhaji.com", "", "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://192.168.200.2:6443", config.RestConfig.Host)
+}
+
+func TestNewDaemonConfigFromKubeconfigNoIngressIp(t *testing.T) {
+	_, path := writeTestKubeconfig(t)
+
+	_, err := NewDaemonConfigFromKubeconfig("", "internal.aleemhaji.com", path, "", "")
+	assert.Equal(t, "ingress IP must be provided", err.Error())
+}
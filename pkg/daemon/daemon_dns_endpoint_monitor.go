@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Owned by external-dns; only the fields used here are defined.
+var DNSEndpointResource = schema.GroupVersionResource{
+	Group:    "externaldns.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "dnsendpoints",
+}
+
+type DNSEndpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DNSEndpointSpec `json:"spec"`
+}
+
+type DNSEndpointSpec struct {
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+}
+
+type Endpoint struct {
+	DNSName    string   `json:"dnsName,omitempty"`
+	Targets    []string `json:"targets,omitempty"`
+	RecordType string   `json:"recordType,omitempty"`
+	RecordTTL  int64    `json:"recordTTL,omitempty"`
+}
+
+type DaemonDNSEndpointMonitor struct {
+	client dynamic.Interface
+}
+
+func (d *DaemonDNSEndpointMonitor) Name() string {
+	return "dnsendpoint"
+}
+
+func (d *DaemonDNSEndpointMonitor) Informer(sif informers.SharedInformerFactory) cache.SharedInformer {
+	return DynamicResourceInformer(sif, d.client, DNSEndpointResource)
+}
+
+func (d *DaemonDNSEndpointMonitor) ValidateResource(obj interface{}) (string, error) {
+	dnsEndpoint, err := dnsEndpointFromObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	objectId := fmt.Sprintf("externaldnsv1alpha1.dnsendpoint/%s/%s", dnsEndpoint.ObjectMeta.Namespace, dnsEndpoint.ObjectMeta.Name)
+
+	if len(d.GetResourceHostsEntries(obj)) == 0 && len(d.GetResourceRecords(obj)) == 0 {
+		return objectId, fmt.Errorf("skipping dnsendpoint (%s) because it doesn't have any A, AAAA, CNAME, SRV or TXT endpoints", objectId)
+	}
+
+	return objectId, nil
+}
+
+// Targets shared by several endpoints are collapsed into a single entry.
+func (d *DaemonDNSEndpointMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
+	dnsEndpoint, err := dnsEndpointFromObject(obj)
+	if err != nil {
+		panic("Failed to get dnsendpoint from pre-validated object.")
+	}
+
+	ips := []string{}
+	hostnamesByIp := map[string][]string{}
+	for _, endpoint := range dnsEndpoint.Spec.Endpoints {
+		if endpoint.RecordType != "A" && endpoint.RecordType != "AAAA" {
+			continue
+		}
+
+		fqdn := strings.TrimSuffix(endpoint.DNSName, ".") + "."
+		for _, target := range endpoint.Targets {
+			if _, ok := hostnamesByIp[target]; !ok {
+				ips = append(ips, target)
+			}
+			hostnamesByIp[target] = append(hostnamesByIp[target], fqdn)
+		}
+	}
+
+	entries := []hostsfile.HostsEntry{}
+	for _, ip := range ips {
+		he := hostsfile.NewHostsEntry(ip, hostnamesByIp[ip])
+		entries = append(entries, *he)
+	}
+
+	return entries
+}
+
+// A name can only have a single CNAME, so only the first target is used.
+// SRV targets are given as "priority weight port target", the same way
+// external-dns takes them; targets that don't parse are left out.
+func (d *DaemonDNSEndpointMonitor) GetResourceRecords(obj interface{}) []hostsfile.Record {
+	dnsEndpoint, err := dnsEndpointFromObject(obj)
+	if err != nil {
+		panic("Failed to get dnsendpoint from pre-validated object.")
+	}
+
+	records := []hostsfile.Record{}
+	for _, endpoint := range dnsEndpoint.Spec.Endpoints {
+		if len(endpoint.Targets) == 0 {
+			continue
+		}
+
+		fqdn := strings.TrimSuffix(endpoint.DNSName, ".") + "."
+		switch endpoint.RecordType {
+		case "CNAME":
+			target := strings.TrimSuffix(endpoint.Targets[0], ".") + "."
+			records = append(records, *hostsfile.NewCNAMERecord(fqdn, target))
+		case "TXT":
+			records = append(records, *hostsfile.NewTXTRecord(fqdn, endpoint.Targets))
+		case "SRV":
+			for _, target := range endpoint.Targets {
+				var priority, weight, port uint16
+				var srvTarget string
+				if _, err := fmt.Sscanf(target, "%d %d %d %s", &priority, &weight, &port, &srvTarget); err != nil {
+					continue
+				}
+				records = append(records, *hostsfile.NewSRVRecord(fqdn, priority, weight, port, srvTarget))
+			}
+		}
+	}
+
+	return records
+}
+
+func dnsEndpointFromObject(obj interface{}) (*DNSEndpoint, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GroupVersionKind().Kind != "DNSEndpoint" {
+		return nil, errors.New("failed to get dnsendpoint from provided object")
+	}
+
+	dnsEndpoint := DNSEndpoint{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &dnsEndpoint); err != nil {
+		return nil, errors.New("failed to get dnsendpoint from provided object")
+	}
+
+	return &dnsEndpoint, nil
+}
@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func validTestDNSEndpoint() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "externaldns.k8s.io/v1alpha1",
+			"kind":       "DNSEndpoint",
+			"metadata": map[string]interface{}{
+				"namespace": "default",
+				"name":      "some-endpoint",
+			},
+			"spec": map[string]interface{}{
+				"endpoints": []interface{}{
+					map[string]interface{}{
+						"dnsName":    "app.internal.aleemhaji.com",
+						"recordType": "A",
+						"targets":    []interface{}{"192.168.1.40"},
+					},
+					map[string]interface{}{
+						"dnsName":    "app.internal.aleemhaji.com",
+						"recordType": "AAAA",
+						"targets":    []interface{}{"fd00::40"},
+					},
+					map[string]interface{}{
+						"dnsName":    "api.internal.aleemhaji.com.",
+						"recordType": "A",
+						"targets":    []interface{}{"192.168.1.40"},
+					},
+					map[string]interface{}{
+						"dnsName":    "www.internal.aleemhaji.com",
+						"recordType": "CNAME",
+						"targets":    []interface{}{"app.internal.aleemhaji.com"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDaemonDNSEndpointMonitorName(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	assert.Equal(t, "dnsendpoint", drm.Name())
+}
+
+func TestDaemonDNSEndpointMonitorValidateResource(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	dnsEndpoint := validTestDNSEndpoint()
+
+	objectId, err := drm.ValidateResource(dnsEndpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, "externaldnsv1alpha1.dnsendpoint/default/some-endpoint", objectId)
+}
+
+func TestDaemonDNSEndpointMonitorValidateResourceNotDNSEndpoint(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	objectId, err := drm.ValidateResource(&drm)
+	assert.Equal(t, "failed to get dnsendpoint from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonDNSEndpointMonitorValidateResourceOnlyRecords(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	dnsEndpoint := validTestDNSEndpoint()
+	endpoints, _, _ := unstructured.NestedSlice(dnsEndpoint.Object, "spec", "endpoints")
+	unstructured.SetNestedSlice(dnsEndpoint.Object, endpoints[3:], "spec", "endpoints")
+
+	objectId, err := drm.ValidateResource(dnsEndpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, "externaldnsv1alpha1.dnsendpoint/default/some-endpoint", objectId)
+}
+
+func TestDaemonDNSEndpointMonitorValidateResourceNoSupportedEndpoints(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	dnsEndpoint := validTestDNSEndpoint()
+	unstructured.SetNestedSlice(dnsEndpoint.Object, []interface{}{
+		map[string]interface{}{
+			"dnsName":    "internal.aleemhaji.com",
+			"recordType": "MX",
+			"targets":    []interface{}{"10 mail.internal.aleemhaji.com"},
+		},
+	}, "spec", "endpoints")
+
+	objectId, err := drm.ValidateResource(dnsEndpoint)
+	assert.Equal(t, "skipping dnsendpoint (externaldnsv1alpha1.dnsendpoint/default/some-endpoint) because it doesn't have any A, AAAA, CNAME, SRV or TXT endpoints", err.Error())
+	assert.Equal(t, "externaldnsv1alpha1.dnsendpoint/default/some-endpoint", objectId)
+}
+
+func TestDaemonDNSEndpointMonitorGetResourceHostsEntries(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	dnsEndpoint := validTestDNSEndpoint()
+
+	e1 := hostsfile.NewHostsEntry("192.168.1.40", []string{"app.internal.aleemhaji.com.", "api.internal.aleemhaji.com."})
+	e2 := hostsfile.NewHostsEntry("fd00::40", []string{"app.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(dnsEndpoint)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1, *e2}, he)
+}
+
+func TestDaemonDNSEndpointMonitorGetResourceRecords(t *testing.T) {
+	drm := DaemonDNSEndpointMonitor{}
+
+	dnsEndpoint := validTestDNSEndpoint()
+	endpoints, _, _ := unstructured.NestedSlice(dnsEndpoint.Object, "spec", "endpoints")
+	endpoints = append(endpoints,
+		map[string]interface{}{
+			"dnsName":    "_http._tcp.app.internal.aleemhaji.com",
+			"recordType": "SRV",
+			"targets":    []interface{}{"0 5 80 app.internal.aleemhaji.com.", "not an srv target"},
+		},
+		map[string]interface{}{
+			"dnsName":    "app.internal.aleemhaji.com",
+			"recordType": "TXT",
+			"targets":    []interface{}{"v=spf1 -all"},
+		},
+	)
+	unstructured.SetNestedSlice(dnsEndpoint.Object, endpoints, "spec", "endpoints")
+
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "app.internal.aleemhaji.com."),
+		*hostsfile.NewSRVRecord("_http._tcp.app.internal.aleemhaji.com.", 0, 5, 80, "app.internal.aleemhaji.com."),
+		*hostsfile.NewTXTRecord("app.internal.aleemhaji.com.", []string{"v=spf1 -all"}),
+	}, drm.GetResourceRecords(dnsEndpoint))
+}
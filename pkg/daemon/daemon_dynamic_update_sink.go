@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Server is the primary to send updates to, and Zone the zone being updated;
+// names outside of it are left out.
+// Updates are signed when a TSIG key is given. The algorithm defaults to
+// hmac-sha256, and the secret is base64, as BIND and Knot give it.
+// StateFile lists every record that was added, so that the first update
+// after a restart can remove the ones that are no longer published.
+type DynamicUpdateOptions struct {
+	Server    string
+	Zone      string
+	Ttl       uint32
+	StateFile string
+
+	TsigName      string
+	TsigSecret    string
+	TsigAlgorithm string
+}
+
+// Keeps a zone on another DNS server up to date with RFC 2136 UPDATE messages.
+// The first update adds every record being published, and removes the
+// records the sink created that no longer are. After that, only the records
+// that were added or removed since the last update are sent.
+// Records the sink didn't create are never removed, even at published names,
+// so without a StateFile, leftovers from a previous run stay in the zone.
+type DaemonDynamicUpdateSink struct {
+	options DynamicUpdateOptions
+	client  *dns.Client
+	owned   *ownedRecords
+
+	synced  bool
+	records []dns.RR
+}
+
+func NewDaemonDynamicUpdateSink(options DynamicUpdateOptions) (*DaemonDynamicUpdateSink, error) {
+	if options.Server == "" {
+		return nil, errors.New("dynamic updates require a server")
+	}
+
+	options.Zone = dns.Fqdn(options.Zone)
+	if options.Zone == "." {
+		return nil, errors.New("dynamic updates require a zone")
+	}
+
+	if options.Ttl == 0 {
+		options.Ttl = 300
+	}
+
+	client := &dns.Client{Net: "tcp", Timeout: 10 * time.Second}
+	if options.TsigName != "" {
+		options.TsigName = dns.Fqdn(options.TsigName)
+		if options.TsigAlgorithm == "" {
+			options.TsigAlgorithm = dns.HmacSHA256
+		}
+		options.TsigAlgorithm = dns.Fqdn(options.TsigAlgorithm)
+		client.TsigSecret = map[string]string{options.TsigName: options.TsigSecret}
+	}
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DaemonDynamicUpdateSink{options: options, client: client, owned: owned}
+	return &d, nil
+}
+
+func (d *DaemonDynamicUpdateSink) Name() string {
+	return fmt.Sprintf("dynamic updates to %s", d.options.Server)
+}
+
+func (d *DaemonDynamicUpdateSink) Update(snapshot hostsfile.Snapshot) error {
+	records := dnsserver.ZoneRecords(d.options.Zone, d.options.Ttl, snapshot)
+	current := map[string]bool{}
+	for _, rr := range records {
+		current[rr.String()] = true
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(d.options.Zone)
+	if !d.synced {
+		stale := []dns.RR{}
+		for _, key := range d.owned.Keys() {
+			if current[key] {
+				continue
+			}
+
+			rr, err := dns.NewRR(key)
+			if err != nil {
+				return err
+			}
+			stale = append(stale, rr)
+		}
+
+		m.Remove(stale)
+		m.Insert(records)
+	} else {
+		removed, added := dnsserver.DiffRecords(d.records, records)
+		if len(removed) == 0 && len(added) == 0 {
+			return nil
+		}
+
+		m.Remove(removed)
+		m.Insert(added)
+	}
+
+	if err := d.send(m); err != nil {
+		return err
+	}
+
+	for _, key := range d.owned.Keys() {
+		if !current[key] {
+			d.owned.Remove(key)
+		}
+	}
+	for key := range current {
+		d.owned.Add(key)
+	}
+
+	d.synced = true
+	d.records = records
+	return d.owned.Save()
+}
+
+func (d *DaemonDynamicUpdateSink) send(m *dns.Msg) error {
+	if d.options.TsigName != "" {
+		m.SetTsig(d.options.TsigName, d.options.TsigAlgorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := d.client.Exchange(m, d.options.Server)
+	if err != nil {
+		return err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update of %s refused by %s: %s", d.options.Zone, d.options.Server, dns.RcodeToString[resp.Rcode])
+	}
+
+	return nil
+}
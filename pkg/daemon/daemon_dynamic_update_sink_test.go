@@ -0,0 +1,200 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+const testTsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0IQ=="
+
+// Applies updates to an in-memory zone, the way a primary would.
+type testUpdateServer struct {
+	lock    sync.Mutex
+	records map[string]dns.RR
+	updates int
+}
+
+func (s *testUpdateServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m := new(dns.Msg)
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		m.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	s.updates++
+	for _, rr := range req.Ns {
+		h := rr.Header()
+		switch h.Class {
+		case dns.ClassANY:
+			for key, existing := range s.records {
+				if existing.Header().Name == h.Name && existing.Header().Rrtype == h.Rrtype {
+					delete(s.records, key)
+				}
+			}
+		case dns.ClassNONE:
+			existing := dns.Copy(rr)
+			existing.Header().Class = dns.ClassINET
+			existing.Header().Ttl = 300
+			delete(s.records, existing.String())
+		default:
+			s.records[rr.String()] = rr
+		}
+	}
+
+	m.SetReply(req)
+	m.SetTsig(req.IsTsig().Hdr.Name, dns.HmacSHA256, 300, time.Now().Unix())
+	w.WriteMsg(m)
+}
+
+func (s *testUpdateServer) Records() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rv := []string{}
+	for key := range s.records {
+		rv = append(rv, key)
+	}
+	sort.Strings(rv)
+
+	return rv
+}
+
+func testDynamicUpdateServer(t *testing.T) (*testUpdateServer, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	us := &testUpdateServer{records: map[string]dns.RR{}}
+	stale, _ := dns.NewRR("stale.internal.aleemhaji.com. 300 IN A 192.168.1.9")
+	old, _ := dns.NewRR("some-service.internal.aleemhaji.com. 300 IN A 192.168.1.9")
+	manual, _ := dns.NewRR("some-service.internal.aleemhaji.com. 300 IN MX 10 mail.internal.aleemhaji.com.")
+	for _, rr := range []dns.RR{stale, old, manual} {
+		us.records[rr.String()] = rr
+	}
+
+	server := dns.Server{
+		Listener:   l,
+		Handler:    us,
+		TsigSecret: map[string]string{"hostsfile-generator.": testTsigSecret},
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+	}
+	go server.ActivateAndServe()
+	t.Cleanup(func() {
+		server.Shutdown()
+	})
+
+	return us, l.Addr().String()
+}
+
+func TestNewDaemonDynamicUpdateSink(t *testing.T) {
+	_, err := NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Zone: "internal.aleemhaji.com"})
+	assert.Equal(t, "dynamic updates require a server", err.Error())
+
+	_, err = NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Server: "127.0.0.1:53"})
+	assert.Equal(t, "dynamic updates require a zone", err.Error())
+
+	d, err := NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Server: "127.0.0.1:53", Zone: "internal.aleemhaji.com", TsigName: "hostsfile-generator"})
+	assert.Nil(t, err)
+	assert.Equal(t, "dynamic updates to 127.0.0.1:53", d.Name())
+	assert.Equal(t, "internal.aleemhaji.com.", d.options.Zone)
+	assert.Equal(t, uint32(300), d.options.Ttl)
+	assert.Equal(t, "hmac-sha256.", d.options.TsigAlgorithm)
+}
+
+func TestDaemonDynamicUpdateSinkUpdate(t *testing.T) {
+	us, addr := testDynamicUpdateServer(t)
+
+	d, err := NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Server: addr, Zone: "internal.aleemhaji.com", TsigName: "hostsfile-generator", TsigSecret: testTsigSecret})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com", "google.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+	})
+
+	// Records the sink didn't create are left alone, even at published names.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.2",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tMX\t10 mail.internal.aleemhaji.com.",
+		"stale.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9",
+		"www.internal.aleemhaji.com.\t300\tIN\tCNAME\tsome-service.internal.aleemhaji.com.",
+	}, us.Records())
+	assert.Equal(t, 1, us.updates)
+
+	// Nothing changed, so nothing is sent.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 1, us.updates)
+
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.3",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tMX\t10 mail.internal.aleemhaji.com.",
+		"stale.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9",
+		"www.internal.aleemhaji.com.\t300\tIN\tCNAME\tsome-service.internal.aleemhaji.com.",
+	}, us.Records())
+	assert.Equal(t, 2, us.updates)
+}
+
+func TestDaemonDynamicUpdateSinkUpdateStateFile(t *testing.T) {
+	us, addr := testDynamicUpdateServer(t)
+
+	stateFile := filepath.Join(t.TempDir(), "dynamic-update.json")
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`["stale.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9"]`), 0644))
+
+	d, err := NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Server: addr, Zone: "internal.aleemhaji.com", TsigName: "hostsfile-generator", TsigSecret: testTsigSecret, StateFile: stateFile})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+
+	// Records created by a previous run that aren't published anymore are
+	// removed too.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.2",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9",
+		"some-service.internal.aleemhaji.com.\t300\tIN\tMX\t10 mail.internal.aleemhaji.com.",
+	}, us.Records())
+
+	contents, err := os.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, `["some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.2"]`, string(contents))
+}
+
+func TestDaemonDynamicUpdateSinkUpdateRefused(t *testing.T) {
+	us, addr := testDynamicUpdateServer(t)
+
+	d, err := NewDaemonDynamicUpdateSink(DynamicUpdateOptions{Server: addr, Zone: "internal.aleemhaji.com"})
+	assert.Nil(t, err)
+
+	err = d.Update(hostsfile.Snapshot{})
+	assert.Equal(t, "update of internal.aleemhaji.com. refused by "+addr+": REFUSED", err.Error())
+	assert.Equal(t, 0, us.updates)
+	assert.False(t, d.synced)
+}
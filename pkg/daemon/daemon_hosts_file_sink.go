@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+const (
+	HostsFileBlockBegin = "# BEGIN hostsfile-generator"
+	HostsFileBlockEnd   = "# END hostsfile-generator"
+)
+
+// Keeps a block of an existing hosts file, like /etc/hosts, up to date,
+// leaving everything outside of the block as it was.
+// The block is added to the end of the file if it isn't there yet.
+// Writers are serialized with a lock on the file itself, and the file is
+// replaced in a single rename, so readers never see it half written. Where it
+// can't be replaced, like when it's bind mounted into a container, it's
+// rewritten in place instead.
+// Names are written without a trailing dot, since resolvers reading the file
+// compare names literally.
+type DaemonHostsFileSink struct {
+	path     string
+	renderer hostsfile.Renderer
+
+	// Replaced in tests, to simulate a file that can't be renamed over.
+	replace func(path string, contents []byte, mode os.FileMode) error
+}
+
+func NewDaemonHostsFileSink(path string, renderer hostsfile.Renderer) *DaemonHostsFileSink {
+	if renderer == nil {
+		renderer = &hostsfile.HostsRenderer{}
+	}
+
+	d := DaemonHostsFileSink{path, renderer, writeFileAtomic}
+	return &d
+}
+
+func (d *DaemonHostsFileSink) Name() string {
+	return fmt.Sprintf("hosts file %s", d.path)
+}
+
+func (d *DaemonHostsFileSink) Update(snapshot hostsfile.Snapshot) error {
+	block, err := d.renderer.Render(relativeHostnames(snapshot))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	updated, err := replaceHostsFileBlock(string(contents), block)
+	if err != nil {
+		return fmt.Errorf("%s: %s", d.path, err.Error())
+	}
+
+	// The provenance header holds the time it was rendered, so it'd always
+	//   look like something changed.
+	if withoutProvenanceHeader(updated) == withoutProvenanceHeader(string(contents)) {
+		return nil
+	}
+
+	if err := d.replace(d.path, []byte(updated), info.Mode().Perm()); err != nil {
+		log.Printf("Failed to replace %s, rewriting it in place instead: %s\n", d.path, err.Error())
+		return writeFileInPlace(f, []byte(updated))
+	}
+
+	return nil
+}
+
+// Strips the trailing dot from every hostname.
+func relativeHostnames(snapshot hostsfile.Snapshot) hostsfile.Snapshot {
+	groups := []hostsfile.EntryGroup{}
+	for _, group := range snapshot.Groups {
+		entries := []hostsfile.HostsEntry{}
+		for _, entry := range group.Entries {
+			hosts := []string{}
+			for _, host := range entry.Hosts() {
+				hosts = append(hosts, strings.TrimSuffix(host, "."))
+			}
+			entries = append(entries, *hostsfile.NewHostsEntry(entry.Ip(), hosts))
+		}
+		groups = append(groups, hostsfile.EntryGroup{ObjectIds: group.ObjectIds, Entries: entries, Records: group.Records})
+	}
+
+	return hostsfile.Snapshot{Groups: groups}
+}
+
+// Anything between the markers is replaced; a file with a beginning marker
+// but no end is left alone, rather than losing whatever follows it.
+func replaceHostsFileBlock(contents, block string) (string, error) {
+	if block != "" && !strings.HasSuffix(block, "\n") {
+		block += "\n"
+	}
+	managed := HostsFileBlockBegin + "\n" + block + HostsFileBlockEnd + "\n"
+
+	lines := strings.SplitAfter(contents, "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == HostsFileBlockBegin && begin == -1 {
+			begin = i
+		} else if trimmed == HostsFileBlockEnd && begin != -1 {
+			end = i
+			break
+		}
+	}
+
+	if begin == -1 {
+		if contents != "" && !strings.HasSuffix(contents, "\n") {
+			contents += "\n"
+		}
+		return contents + managed, nil
+	}
+
+	if end == -1 {
+		return "", fmt.Errorf("found %q without %q", HostsFileBlockBegin, HostsFileBlockEnd)
+	}
+
+	return strings.Join(lines[:begin], "") + managed + strings.Join(lines[end+1:], ""), nil
+}
+
+func withoutProvenanceHeader(contents string) string {
+	header := "# " + hostsfile.ProvenanceHeader + " "
+
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(contents, "\n") {
+		if !strings.HasPrefix(line, header) {
+			sb.WriteString(line)
+		}
+	}
+
+	return sb.String()
+}
+
+// Readers can see the file half written, so it's only used when the file
+// can't be replaced.
+func writeFileInPlace(f *os.File, contents []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := f.WriteAt(contents, 0); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func writeFileAtomic(path string, contents []byte, mode os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func testHostsFileSnapshot(ip string) hostsfile.Snapshot {
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry(ip, []string{"some-service.internal.aleemhaji.com"}),
+	})
+
+	return hf.Snapshot()
+}
+
+func TestDaemonHostsFileSinkName(t *testing.T) {
+	d := NewDaemonHostsFileSink("/etc/hosts", nil)
+	assert.Equal(t, "hosts file /etc/hosts", d.Name())
+}
+
+func TestDaemonHostsFileSinkUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	assert.Nil(t, os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n::1\tlocalhost"), 0640))
+
+	d := NewDaemonHostsFileSink(path, nil)
+
+	// Added to the end of the file the first time.
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.2")))
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1\tlocalhost\n::1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.2\tsome-service.internal.aleemhaji.com\n# END hostsfile-generator\n", string(contents))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// Replaced in place after that, keeping everything around it.
+	assert.Nil(t, os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.2\tsome-service.internal.aleemhaji.com\n# END hostsfile-generator\n192.168.1.1\trouter\n"), 0640))
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.3")))
+	contents, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.3\tsome-service.internal.aleemhaji.com\n# END hostsfile-generator\n192.168.1.1\trouter\n", string(contents))
+}
+
+func TestDaemonHostsFileSinkUpdateProvenance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+
+	renderer, err := hostsfile.NewRenderer("hosts", hostsfile.RenderOptions{Provenance: true, Version: "v1.2.3"})
+	assert.Nil(t, err)
+	d := NewDaemonHostsFileSink(path, renderer)
+
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.2")))
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	// Pretend it was written a while ago, so the header would be different.
+	lines := strings.SplitN(string(contents), "\n", 3)
+	assert.True(t, strings.HasPrefix(lines[1], "# Generated by hostsfile-generator v1.2.3 at "))
+	original := lines[0] + "\n# Generated by hostsfile-generator v1.2.3 at 2020-01-01T00:00:00Z\n" + lines[2]
+	assert.Nil(t, os.WriteFile(path, []byte(original), 0644))
+
+	// Nothing changed, so the file isn't rewritten.
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.2")))
+	contents, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, original, string(contents))
+
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.3")))
+	contents, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "2020-01-01T00:00:00Z")
+	assert.Contains(t, string(contents), "192.168.1.3\tsome-service.internal.aleemhaji.com\n")
+}
+
+func TestDaemonHostsFileSinkUpdateMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+
+	d := NewDaemonHostsFileSink(path, nil)
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.2")))
+
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "# BEGIN hostsfile-generator\n192.168.1.2\tsome-service.internal.aleemhaji.com\n# END hostsfile-generator\n", string(contents))
+}
+
+func TestDaemonHostsFileSinkUpdateFullyQualifiedNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com.", "some-service."}),
+	})
+
+	d := NewDaemonHostsFileSink(path, nil)
+	assert.Nil(t, d.Update(hf.Snapshot()))
+
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "# BEGIN hostsfile-generator\n192.168.1.2\tsome-service.internal.aleemhaji.com\tsome-service\n# END hostsfile-generator\n", string(contents))
+
+	// The file itself is locked, so nothing is left next to it.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestDaemonHostsFileSinkUpdateInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	assert.Nil(t, os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0644))
+
+	// Like a bind mounted file, which can't be renamed over.
+	d := NewDaemonHostsFileSink(path, nil)
+	d.replace = func(path string, contents []byte, mode os.FileMode) error {
+		return &os.LinkError{Op: "rename", Old: path + ".tmp", New: path, Err: syscall.EBUSY}
+	}
+
+	assert.Nil(t, d.Update(testHostsFileSnapshot("192.168.1.2")))
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.2\tsome-service.internal.aleemhaji.com\n# END hostsfile-generator\n", string(contents))
+
+	// Shorter than what's there, so what's left over has to be truncated.
+	assert.Nil(t, d.Update(hostsfile.NewHostsFile().Snapshot()))
+	contents, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n# END hostsfile-generator\n", string(contents))
+}
+
+func TestDaemonHostsFileSinkUpdateUnterminatedBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	original := "127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.1\trouter\n"
+	assert.Nil(t, os.WriteFile(path, []byte(original), 0644))
+
+	d := NewDaemonHostsFileSink(path, nil)
+	err := d.Update(testHostsFileSnapshot("192.168.1.2"))
+	assert.Equal(t, path+": found \"# BEGIN hostsfile-generator\" without \"# END hostsfile-generator\"", err.Error())
+
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, original, string(contents))
+}
+
+func TestReplaceHostsFileBlockEmpty(t *testing.T) {
+	rv, err := replaceHostsFileBlock("127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n192.168.1.2\tsome-service\n# END hostsfile-generator\n", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1\tlocalhost\n# BEGIN hostsfile-generator\n# END hostsfile-generator\n", rv)
+}
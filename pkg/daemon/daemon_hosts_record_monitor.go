@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Defined in manifests/hostsrecord-crd.yaml.
+var HostsRecordResource = schema.GroupVersionResource{
+	Group:    "hostsfile-generator.aleemhaji.com",
+	Version:  "v1alpha1",
+	Resource: "hostsrecords",
+}
+
+type HostsRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HostsRecordSpec `json:"spec"`
+}
+
+type HostsRecordSpec struct {
+	Ip        string   `json:"ip,omitempty"`
+	Hostnames []string `json:"hostnames"`
+
+	// Type is inferred from the IP when omitted.
+	// TTL is only a hint; the hosts format has no way of expressing it.
+	Type string `json:"type,omitempty"`
+	Ttl  *int32 `json:"ttl,omitempty"`
+
+	// Used by CNAME and SRV records; fully qualified targets are left as they
+	// are, and other targets are qualified the same way as hostnames.
+	Target   string `json:"target,omitempty"`
+	Priority int32  `json:"priority,omitempty"`
+	Weight   int32  `json:"weight,omitempty"`
+	Port     int32  `json:"port,omitempty"`
+
+	Text []string `json:"text,omitempty"`
+}
+
+type DaemonHostsRecordMonitor struct {
+	client       dynamic.Interface
+	searchDomain string
+}
+
+func (d *DaemonHostsRecordMonitor) Name() string {
+	return "hostsrecord"
+}
+
+func (d *DaemonHostsRecordMonitor) Informer(sif informers.SharedInformerFactory) cache.SharedInformer {
+	return DynamicResourceInformer(sif, d.client, HostsRecordResource)
+}
+
+func (d *DaemonHostsRecordMonitor) ValidateResource(obj interface{}) (string, error) {
+	record, err := hostsRecordFromObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	objectId := fmt.Sprintf("hostsfilev1alpha1.hostsrecord/%s/%s", record.ObjectMeta.Namespace, record.ObjectMeta.Name)
+
+	if len(record.Spec.Hostnames) == 0 {
+		return objectId, fmt.Errorf("skipping hostsrecord (%s) because it doesn't have any hostnames", objectId)
+	}
+
+	switch record.Spec.Type {
+	case "", "A", "AAAA":
+		ip := net.ParseIP(record.Spec.Ip)
+		if ip == nil {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because it doesn't have a valid ip", objectId)
+		}
+
+		if record.Spec.Type == "A" && ip.To4() == nil {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because type A requires an IPv4 address", objectId)
+		}
+
+		if record.Spec.Type == "AAAA" && ip.To4() != nil {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because type AAAA requires an IPv6 address", objectId)
+		}
+	case "CNAME":
+		if record.Spec.Target == "" {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because type CNAME requires a target", objectId)
+		}
+	case "SRV":
+		if record.Spec.Target == "" || record.Spec.Port <= 0 || record.Spec.Port > 65535 {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because type SRV requires a target and port", objectId)
+		}
+	case "TXT":
+		if len(record.Spec.Text) == 0 {
+			return objectId, fmt.Errorf("skipping hostsrecord (%s) because type TXT requires text", objectId)
+		}
+	default:
+		return objectId, fmt.Errorf("skipping hostsrecord (%s) because type %s isn't supported", objectId, record.Spec.Type)
+	}
+
+	return objectId, nil
+}
+
+func (d *DaemonHostsRecordMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
+	record, err := hostsRecordFromObject(obj)
+	if err != nil {
+		panic("Failed to get hostsrecord from pre-validated object.")
+	}
+
+	switch record.Spec.Type {
+	case "CNAME", "SRV", "TXT":
+		return []hostsfile.HostsEntry{}
+	}
+
+	hostnames := []string{}
+	for _, name := range record.Spec.Hostnames {
+		hostnames = append(hostnames, qualifyHostname(name, d.searchDomain))
+	}
+
+	he := hostsfile.NewHostsEntry(record.Spec.Ip, hostnames)
+	return []hostsfile.HostsEntry{*he}
+}
+
+func (d *DaemonHostsRecordMonitor) GetResourceRecords(obj interface{}) []hostsfile.Record {
+	record, err := hostsRecordFromObject(obj)
+	if err != nil {
+		panic("Failed to get hostsrecord from pre-validated object.")
+	}
+
+	target := record.Spec.Target
+	if !strings.HasSuffix(target, ".") {
+		target = qualifyHostname(target, d.searchDomain)
+	}
+
+	records := []hostsfile.Record{}
+	for _, name := range record.Spec.Hostnames {
+		hostname := qualifyHostname(name, d.searchDomain)
+		switch record.Spec.Type {
+		case "CNAME":
+			records = append(records, *hostsfile.NewCNAMERecord(hostname, target))
+		case "SRV":
+			records = append(records, *hostsfile.NewSRVRecord(hostname, uint16(record.Spec.Priority), uint16(record.Spec.Weight), uint16(record.Spec.Port), target))
+		case "TXT":
+			records = append(records, *hostsfile.NewTXTRecord(hostname, record.Spec.Text))
+		}
+	}
+
+	return records
+}
+
+func hostsRecordFromObject(obj interface{}) (*HostsRecord, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GroupVersionKind().Kind != "HostsRecord" {
+		return nil, errors.New("failed to get hostsrecord from provided object")
+	}
+
+	record := HostsRecord{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &record); err != nil {
+		return nil, errors.New("failed to get hostsrecord from provided object")
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func validTestHostsRecord() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "hostsfile-generator.aleemhaji.com/v1alpha1",
+			"kind":       "HostsRecord",
+			"metadata": map[string]interface{}{
+				"namespace": "default",
+				"name":      "nas",
+			},
+			"spec": map[string]interface{}{
+				"ip":        "192.168.1.30",
+				"hostnames": []interface{}{"nas", "files.internal.aleemhaji.com"},
+			},
+		},
+	}
+}
+
+func TestDaemonHostsRecordMonitorName(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{}
+
+	assert.Equal(t, "hostsrecord", drm.Name())
+}
+
+func TestDaemonHostsRecordMonitorValidateResource(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{}
+
+	record := validTestHostsRecord()
+
+	objectId, err := drm.ValidateResource(record)
+	assert.Nil(t, err)
+	assert.Equal(t, "hostsfilev1alpha1.hostsrecord/default/nas", objectId)
+}
+
+func TestDaemonHostsRecordMonitorValidateResourceNotHostsRecord(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{}
+
+	objectId, err := drm.ValidateResource(&drm)
+	assert.Equal(t, "failed to get hostsrecord from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+
+	record := validTestHostsRecord()
+	record.SetKind("SomethingElse")
+
+	objectId, err = drm.ValidateResource(record)
+	assert.Equal(t, "failed to get hostsrecord from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonHostsRecordMonitorValidateResourceInvalidIp(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{}
+
+	record := validTestHostsRecord()
+	unstructured.SetNestedField(record.Object, "192.168.1", "spec", "ip")
+
+	objectId, err := drm.ValidateResource(record)
+	assert.Equal(t, "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because it doesn't have a valid ip", err.Error())
+	assert.Equal(t, "hostsfilev1alpha1.hostsrecord/default/nas", objectId)
+}
+
+func TestDaemonHostsRecordMonitorValidateResourceNoHostnames(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{}
+
+	record := validTestHostsRecord()
+	unstructured.RemoveNestedField(record.Object, "spec", "hostnames")
+
+	objectId, err := drm.ValidateResource(record)
+	assert.Equal(t, "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because it doesn't have any hostnames", err.Error())
+	assert.Equal(t, "hostsfilev1alpha1.hostsrecord/default/nas", objectId)
+}
+
+func TestDaemonHostsRecordMonitorValidateResourceType(t *testing.T) {
+	var tests = []struct {
+		name       string
+		ip         string
+		recordType string
+		err        string
+	}{
+		{"A", "192.168.1.30", "A", ""},
+		{"AAAA", "fd00::30", "AAAA", ""},
+		{"A With IPv6", "fd00::30", "A", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type A requires an IPv4 address"},
+		{"AAAA With IPv4", "192.168.1.30", "AAAA", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type AAAA requires an IPv6 address"},
+		{"Unsupported", "192.168.1.30", "MX", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type MX isn't supported"},
+		{"CNAME Without Target", "", "CNAME", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type CNAME requires a target"},
+		{"SRV Without Target", "", "SRV", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type SRV requires a target and port"},
+		{"TXT Without Text", "", "TXT", "skipping hostsrecord (hostsfilev1alpha1.hostsrecord/default/nas) because type TXT requires text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drm := DaemonHostsRecordMonitor{}
+
+			record := validTestHostsRecord()
+			unstructured.SetNestedField(record.Object, tt.ip, "spec", "ip")
+			unstructured.SetNestedField(record.Object, tt.recordType, "spec", "type")
+
+			_, err := drm.ValidateResource(record)
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestDaemonHostsRecordMonitorGetResourceHostsEntries(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{nil, "internal.aleemhaji.com"}
+
+	record := validTestHostsRecord()
+
+	e := hostsfile.NewHostsEntry("192.168.1.30", []string{"nas.internal.aleemhaji.com.", "files.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(record)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
+}
+
+func TestDaemonHostsRecordMonitorGetResourceRecords(t *testing.T) {
+	drm := DaemonHostsRecordMonitor{nil, "internal.aleemhaji.com"}
+
+	record := validTestHostsRecord()
+	unstructured.RemoveNestedField(record.Object, "spec", "ip")
+	unstructured.SetNestedField(record.Object, "CNAME", "spec", "type")
+	unstructured.SetNestedField(record.Object, "storage", "spec", "target")
+
+	_, err := drm.ValidateResource(record)
+	assert.Nil(t, err)
+	assert.Equal(t, []hostsfile.HostsEntry{}, drm.GetResourceHostsEntries(record))
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("nas.internal.aleemhaji.com.", "storage.internal.aleemhaji.com."),
+		*hostsfile.NewCNAMERecord("files.internal.aleemhaji.com.", "storage.internal.aleemhaji.com."),
+	}, drm.GetResourceRecords(record))
+
+	unstructured.SetNestedField(record.Object, "SRV", "spec", "type")
+	unstructured.SetNestedField(record.Object, "nas.example.com.", "spec", "target")
+	unstructured.SetNestedField(record.Object, int64(445), "spec", "port")
+	unstructured.SetNestedField(record.Object, int64(10), "spec", "priority")
+	unstructured.SetNestedStringSlice(record.Object, []string{"_smb._tcp.nas"}, "spec", "hostnames")
+
+	_, err = drm.ValidateResource(record)
+	assert.Nil(t, err)
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewSRVRecord("_smb._tcp.nas.internal.aleemhaji.com.", 10, 0, 445, "nas.example.com."),
+	}, drm.GetResourceRecords(record))
+
+	unstructured.SetNestedField(record.Object, "TXT", "spec", "type")
+	unstructured.SetNestedStringSlice(record.Object, []string{"owner=storage"}, "spec", "text")
+
+	_, err = drm.ValidateResource(record)
+	assert.Nil(t, err)
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewTXTRecord("_smb._tcp.nas.internal.aleemhaji.com.", []string{"owner=storage"}),
+	}, drm.GetResourceRecords(record))
+}
@@ -52,7 +52,7 @@ func (d *DaemonIngressMonitor) ValidateResource(obj interface{}) (string, error)
 	return objectId, nil
 }
 
-func (d *DaemonIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsfile.HostsEntry {
+func (d *DaemonIngressMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
 	ingress, ok := obj.(*networkingv1.Ingress)
 	if !ok {
 		panic("Failed to get Ingress from pre-validated type.")
@@ -61,6 +61,11 @@ func (d *DaemonIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsfile.
 	hostnames := []string{}
 
 	for _, rule := range ingress.Spec.Rules {
+		// Catch-all rules don't have a name to publish.
+		if rule.Host == "" {
+			continue
+		}
+
 		if strings.HasSuffix(rule.Host, d.searchDomain) {
 			hostnames = append(hostnames, rule.Host+".")
 		} else {
@@ -69,5 +74,5 @@ func (d *DaemonIngressMonitor) GetResourceHostsEntry(obj interface{}) hostsfile.
 	}
 
 	he := hostsfile.NewHostsEntry(d.ingressIp, hostnames)
-	return *he
+	return []hostsfile.HostsEntry{*he}
 }
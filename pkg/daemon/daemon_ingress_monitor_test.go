@@ -86,14 +86,14 @@ func TestDaemonIngressMonitorValidateResourceNotNginxIngress(t *testing.T) {
 	assert.Equal(t, "networkingv1.ingress/default/some-ingress", objectId)
 }
 
-func TestDaemonIngressMonitorGetResourceHostsEntry(t *testing.T) {
+func TestDaemonIngressMonitorGetResourceHostsEntries(t *testing.T) {
 	drm := DaemonIngressMonitor{"192.168.1.1", "internal.aleemhaji.com"}
 
 	ingress := validTestIngress()
 
 	e := hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress.internal.aleemhaji.com."})
-	he := drm.GetResourceHostsEntry(ingress)
-	assert.Equal(t, *e, he)
+	he := drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
 
 	ingress.Spec.Rules = []networkingv1.IngressRule{
 		networkingv1.IngressRule{
@@ -102,6 +102,17 @@ func TestDaemonIngressMonitorGetResourceHostsEntry(t *testing.T) {
 	}
 
 	e = hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress"})
-	he = drm.GetResourceHostsEntry(ingress)
-	assert.Equal(t, *e, he)
+	he = drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
+}
+
+func TestDaemonIngressMonitorGetResourceHostsEntriesCatchAll(t *testing.T) {
+	drm := DaemonIngressMonitor{"192.168.1.1", "internal.aleemhaji.com"}
+
+	ingress := validTestIngress()
+	ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{})
+
+	e := hostsfile.NewHostsEntry("192.168.1.1", []string{"some-ingress.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(ingress)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
 }
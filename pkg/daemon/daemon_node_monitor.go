@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+type DaemonNodeMonitor struct {
+	searchDomain string
+	externalIps  bool
+}
+
+func (d *DaemonNodeMonitor) Name() string {
+	return "node"
+}
+
+func (d *DaemonNodeMonitor) Informer(sif informers.SharedInformerFactory) cache.SharedInformer {
+	return sif.Core().V1().Nodes().Informer()
+}
+
+func (d *DaemonNodeMonitor) ValidateResource(obj interface{}) (string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return "", errors.New("failed to get node from provided object")
+	}
+
+	objectId := fmt.Sprintf("v1.node/%s", node.ObjectMeta.Name)
+
+	if len(d.nodeAddresses(node)) == 0 {
+		return objectId, fmt.Errorf("skipping node (%s) because it doesn't have any usable addresses", objectId)
+	}
+
+	return objectId, nil
+}
+
+func (d *DaemonNodeMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		panic("Failed to get node from pre-validated object.")
+	}
+
+	fqdn := fmt.Sprintf("%s.%s.", node.ObjectMeta.Name, d.searchDomain)
+
+	entries := []hostsfile.HostsEntry{}
+	for _, address := range d.nodeAddresses(node) {
+		he := hostsfile.NewHostsEntry(address, []string{fqdn})
+		entries = append(entries, *he)
+	}
+
+	return entries
+}
+
+// InternalIPs are always published; ExternalIPs only if the monitor has been
+// configured to include them.
+func (d *DaemonNodeMonitor) nodeAddresses(node *v1.Node) []string {
+	addresses := []string{}
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP || (d.externalIps && address.Type == v1.NodeExternalIP) {
+			addresses = append(addresses, address.Address)
+		}
+	}
+
+	return addresses
+}
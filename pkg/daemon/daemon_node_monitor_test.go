@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func validTestNode() *v1.Node {
+	node := v1.Node{}
+	node.ObjectMeta.Name = "some-node"
+	node.Status.Addresses = []v1.NodeAddress{
+		v1.NodeAddress{Type: v1.NodeHostName, Address: "some-node"},
+		v1.NodeAddress{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+		v1.NodeAddress{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+	}
+
+	return &node
+}
+
+func TestDaemonNodeMonitorName(t *testing.T) {
+	drm := DaemonNodeMonitor{}
+
+	assert.Equal(t, "node", drm.Name())
+}
+
+func TestDaemonNodeMonitorValidateResource(t *testing.T) {
+	drm := DaemonNodeMonitor{}
+
+	node := validTestNode()
+
+	objectId, err := drm.ValidateResource(node)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.node/some-node", objectId)
+}
+
+func TestDaemonNodeMonitorValidateResourceNotNode(t *testing.T) {
+	drm := DaemonNodeMonitor{}
+
+	objectId, err := drm.ValidateResource(&drm)
+	assert.Equal(t, "failed to get node from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonNodeMonitorValidateResourceNoAddresses(t *testing.T) {
+	drm := DaemonNodeMonitor{}
+
+	node := validTestNode()
+	node.Status.Addresses = node.Status.Addresses[2:]
+
+	objectId, err := drm.ValidateResource(node)
+	assert.Equal(t, "skipping node (v1.node/some-node) because it doesn't have any usable addresses", err.Error())
+	assert.Equal(t, "v1.node/some-node", objectId)
+
+	drm.externalIps = true
+
+	objectId, err = drm.ValidateResource(node)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.node/some-node", objectId)
+}
+
+func TestDaemonNodeMonitorGetResourceHostsEntries(t *testing.T) {
+	drm := DaemonNodeMonitor{"internal.aleemhaji.com", false}
+
+	node := validTestNode()
+
+	e1 := hostsfile.NewHostsEntry("192.168.1.10", []string{"some-node.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(node)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1}, he)
+
+	drm.externalIps = true
+
+	e2 := hostsfile.NewHostsEntry("203.0.113.10", []string{"some-node.internal.aleemhaji.com."})
+	he = drm.GetResourceHostsEntries(node)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1, *e2}, he)
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The records a sink created itself, so that it never removes records that
+// were put there some other way.
+// Kept in a file, if given, so ownership survives restarts; otherwise records
+// left behind by a previous run are never cleaned up.
+type ownedRecords struct {
+	path  string
+	owned map[string]bool
+}
+
+func newOwnedRecords(path string) (*ownedRecords, error) {
+	o := ownedRecords{path, map[string]bool{}}
+	if path == "" {
+		return &o, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &o, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	if err := json.Unmarshal(contents, &keys); err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		o.owned[key] = true
+	}
+
+	return &o, nil
+}
+
+func (o *ownedRecords) Owns(key string) bool {
+	return o.owned[key]
+}
+
+func (o *ownedRecords) Add(key string) {
+	o.owned[key] = true
+}
+
+func (o *ownedRecords) Remove(key string) {
+	delete(o.owned, key)
+}
+
+func (o *ownedRecords) Keys() []string {
+	keys := []string{}
+	for key := range o.owned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Written to a temporary file and renamed over the old one, so a crash never
+// leaves a truncated file behind.
+func (o *ownedRecords) Save() error {
+	if o.path == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(o.Keys())
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(o.path), ".owned")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), o.path)
+}
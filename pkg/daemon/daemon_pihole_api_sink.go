@@ -0,0 +1,265 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Url is the Pi-hole's web interface, e.g. http://pi.hole.
+// Password is the Pi-hole's web or app password, and can be left out if it
+// doesn't have one.
+// Records the sink added are listed in StateFile, if given. Without it, any
+// that stop being published while the daemon is down are never removed from
+// the Pi-hole.
+type PiholeAPIOptions struct {
+	Url       string
+	Password  string
+	StateFile string
+}
+
+// Reconciles the Pi-hole's local DNS and CNAME records through its HTTP API,
+// so that nothing has to be copied into its pod, and its DNS service never has
+// to restart.
+// Records that are missing are added, and records that the sink added that
+// are no longer published are removed; records added some other way are left
+// alone. Wildcards, and SRV and TXT records, can't be added as local records,
+// so they're left out.
+type DaemonPiholeAPISink struct {
+	options PiholeAPIOptions
+	client  *http.Client
+	sid     string
+	owned   *ownedRecords
+}
+
+// The lists of local records in the Pi-hole's configuration, and the prefixes
+// their entries are owned under.
+var piholeAPILists = []struct {
+	path   string
+	field  string
+	prefix string
+}{
+	{"/api/config/dns/hosts", "hosts", "hosts:"},
+	{"/api/config/dns/cnameRecords", "cnameRecords", "cname:"},
+}
+
+type piholeAPIConfig struct {
+	Config struct {
+		Dns map[string][]string `json:"dns"`
+	} `json:"config"`
+}
+
+type piholeAPIAuth struct {
+	Session struct {
+		Valid bool   `json:"valid"`
+		Sid   string `json:"sid"`
+	} `json:"session"`
+}
+
+type piholeAPIError struct {
+	Error struct {
+		Key     string `json:"key"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewDaemonPiholeAPISink(options PiholeAPIOptions) (*DaemonPiholeAPISink, error) {
+	if options.Url == "" {
+		return nil, errors.New("pi-hole api requires a url")
+	}
+	options.Url = strings.TrimSuffix(options.Url, "/")
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DaemonPiholeAPISink{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		owned:   owned,
+	}
+	return &d, nil
+}
+
+func (d *DaemonPiholeAPISink) Name() string {
+	return fmt.Sprintf("pi-hole api at %s", d.options.Url)
+}
+
+func (d *DaemonPiholeAPISink) Update(snapshot hostsfile.Snapshot) error {
+	desired := piholeAPIRecords(snapshot)
+
+	for i, list := range piholeAPILists {
+		config := piholeAPIConfig{}
+		if err := d.request(http.MethodGet, list.path, &config); err != nil {
+			return err
+		}
+
+		existing := map[string]bool{}
+		for _, item := range config.Config.Dns[list.field] {
+			existing[item] = true
+		}
+
+		for _, item := range desired[i] {
+			if existing[item] {
+				continue
+			}
+
+			if err := d.request(http.MethodPut, list.path+"/"+url.PathEscape(item), nil); err != nil {
+				return err
+			}
+			d.owned.Add(list.prefix + item)
+		}
+
+		wanted := map[string]bool{}
+		for _, item := range desired[i] {
+			wanted[item] = true
+		}
+
+		for _, key := range d.owned.Keys() {
+			item := strings.TrimPrefix(key, list.prefix)
+			if item == key || wanted[item] {
+				continue
+			}
+
+			if existing[item] {
+				if err := d.request(http.MethodDelete, list.path+"/"+url.PathEscape(item), nil); err != nil {
+					return err
+				}
+			}
+			d.owned.Remove(key)
+		}
+	}
+
+	return d.owned.Save()
+}
+
+// Signs in again if the session has expired.
+func (d *DaemonPiholeAPISink) request(method, path string, response interface{}) error {
+	if d.sid == "" && d.options.Password != "" {
+		if err := d.authenticate(); err != nil {
+			return err
+		}
+	}
+
+	resp, err := d.do(method, path, nil)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && d.options.Password != "" {
+		resp.Body.Close()
+		if err := d.authenticate(); err != nil {
+			return err
+		}
+		resp, err = d.do(method, path, nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return piholeAPIResponse(method, path, resp, response)
+}
+
+func (d *DaemonPiholeAPISink) authenticate() error {
+	body, err := json.Marshal(map[string]string{"password": d.options.Password})
+	if err != nil {
+		return err
+	}
+
+	d.sid = ""
+	resp, err := d.do(http.MethodPost, "/api/auth", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	auth := piholeAPIAuth{}
+	if err := piholeAPIResponse(http.MethodPost, "/api/auth", resp, &auth); err != nil {
+		return err
+	}
+
+	if !auth.Session.Valid || auth.Session.Sid == "" {
+		return errors.New("pi-hole api didn't return a valid session")
+	}
+
+	d.sid = auth.Session.Sid
+	return nil
+}
+
+func (d *DaemonPiholeAPISink) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, d.options.Url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if d.sid != "" {
+		req.Header.Set("X-FTL-SID", d.sid)
+	}
+
+	return d.client.Do(req)
+}
+
+func piholeAPIResponse(method, path string, resp *http.Response, response interface{}) error {
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiError := piholeAPIError{}
+		if json.Unmarshal(contents, &apiError) == nil && apiError.Error.Message != "" {
+			return fmt.Errorf("pi-hole api %s %s failed: %s", method, path, apiError.Error.Message)
+		}
+		return fmt.Errorf("pi-hole api %s %s failed: %s", method, path, resp.Status)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(contents, response)
+}
+
+// Local DNS records as "ip hostname", and CNAME records as "name,target", in
+// the same order as piholeAPILists.
+func piholeAPIRecords(snapshot hostsfile.Snapshot) [][]string {
+	hosts, cnames := []string{}, []string{}
+	seen := map[string]bool{}
+	add := func(list *[]string, item string) {
+		if !seen[item] {
+			seen[item] = true
+			*list = append(*list, item)
+		}
+	}
+
+	for _, group := range snapshot.Groups {
+		for _, entry := range group.Entries {
+			for _, host := range entry.Hosts() {
+				if strings.HasPrefix(host, "*.") {
+					continue
+				}
+				add(&hosts, fmt.Sprintf("%s %s", entry.Ip(), bareHostname(host)))
+			}
+		}
+
+		for _, record := range group.Records {
+			if record.Type() == hostsfile.RecordCNAME {
+				add(&cnames, fmt.Sprintf("%s,%s", bareHostname(record.Name()), bareHostname(record.Target())))
+			}
+		}
+	}
+
+	return [][]string{hosts, cnames}
+}
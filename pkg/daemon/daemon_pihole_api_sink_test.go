@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Enough of the Pi-hole API to manage local records, with sessions that can
+// be expired.
+type testPiholeAPI struct {
+	lock     sync.Mutex
+	password string
+	sid      string
+	logins   int
+	lists    map[string][]string
+}
+
+func (p *testPiholeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r.URL.Path == "/api/auth" {
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["password"] != p.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"key":"unauthorized","message":"Unauthorized"}}`))
+			return
+		}
+
+		p.logins++
+		p.sid = "sid" + strconv.Itoa(p.logins)
+		w.Write([]byte(`{"session":{"valid":true,"sid":"` + p.sid + `"}}`))
+		return
+	}
+
+	if r.Header.Get("X-FTL-SID") != p.sid {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"key":"unauthorized","message":"Unauthorized"}}`))
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/api/config/dns/"), "/", 2)
+	list := parts[0]
+	if _, ok := p.lists[list]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"config": map[string]interface{}{"dns": map[string][]string{list: p.lists[list]}}})
+	case http.MethodPut:
+		item, _ := url.PathUnescape(parts[1])
+		for _, existing := range p.lists[list] {
+			if existing == item {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"key":"bad_request","message":"Item already present"}}`))
+				return
+			}
+		}
+		p.lists[list] = append(p.lists[list], item)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		item, _ := url.PathUnescape(parts[1])
+		items := []string{}
+		for _, existing := range p.lists[list] {
+			if existing != item {
+				items = append(items, existing)
+			}
+		}
+		p.lists[list] = items
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testPiholeAPIServer(t *testing.T) (*testPiholeAPI, string) {
+	p := &testPiholeAPI{
+		password: "hunter2",
+		lists: map[string][]string{
+			"hosts":        []string{"192.168.1.1 router.internal.aleemhaji.com"},
+			"cnameRecords": []string{},
+		},
+	}
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	return p, server.URL
+}
+
+func TestNewDaemonPiholeAPISink(t *testing.T) {
+	_, err := NewDaemonPiholeAPISink(PiholeAPIOptions{})
+	assert.Equal(t, "pi-hole api requires a url", err.Error())
+
+	d, err := NewDaemonPiholeAPISink(PiholeAPIOptions{Url: "http://pi.hole/"})
+	assert.Nil(t, err)
+	assert.Equal(t, "pi-hole api at http://pi.hole", d.Name())
+}
+
+func TestDaemonPiholeAPISinkUpdate(t *testing.T) {
+	p, serverUrl := testPiholeAPIServer(t)
+	stateFile := filepath.Join(t.TempDir(), "owned.json")
+
+	d, err := NewDaemonPiholeAPISink(PiholeAPIOptions{Url: serverUrl, Password: "hunter2", StateFile: stateFile})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com.", "*.apps.internal.aleemhaji.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.1", []string{"router.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewTXTRecord("some-service.internal.aleemhaji.com.", []string{"v=spf1 -all"}),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{"192.168.1.1 router.internal.aleemhaji.com", "192.168.1.2 some-service.internal.aleemhaji.com"}, p.lists["hosts"])
+	assert.Equal(t, []string{"www.internal.aleemhaji.com,some-service.internal.aleemhaji.com"}, p.lists["cnameRecords"])
+	assert.Equal(t, 1, p.logins)
+
+	contents, err := os.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, `["cname:www.internal.aleemhaji.com,some-service.internal.aleemhaji.com","hosts:192.168.1.2 some-service.internal.aleemhaji.com"]`, string(contents))
+
+	// Records the sink didn't add are never removed, even once they're no
+	// longer published. Expired sessions are replaced.
+	p.sid = "expired"
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{"192.168.1.1 router.internal.aleemhaji.com", "192.168.1.3 some-service.internal.aleemhaji.com"}, p.lists["hosts"])
+	assert.Equal(t, []string{}, p.lists["cnameRecords"])
+	assert.Equal(t, 2, p.logins)
+
+	// Ownership is picked up again after a restart.
+	d, err = NewDaemonPiholeAPISink(PiholeAPIOptions{Url: serverUrl, Password: "hunter2", StateFile: stateFile})
+	assert.Nil(t, err)
+	assert.Nil(t, d.Update(hostsfile.Snapshot{}))
+	assert.Equal(t, []string{"192.168.1.1 router.internal.aleemhaji.com"}, p.lists["hosts"])
+}
+
+func TestDaemonPiholeAPISinkUpdateBadPassword(t *testing.T) {
+	_, serverUrl := testPiholeAPIServer(t)
+
+	d, err := NewDaemonPiholeAPISink(PiholeAPIOptions{Url: serverUrl, Password: "wrong"})
+	assert.Nil(t, err)
+
+	err = d.Update(hostsfile.Snapshot{})
+	assert.Equal(t, "pi-hole api POST /api/auth failed: Unauthorized", err.Error())
+}
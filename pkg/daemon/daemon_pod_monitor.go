@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Comma separated list of names to publish for the pod.
+// Names that don't already end with the search domain have it appended.
+const PodHostnameAnnotation string = "hostsfile-generator/hostname"
+
+type DaemonPodMonitor struct {
+	searchDomain string
+}
+
+func (d *DaemonPodMonitor) Name() string {
+	return "pod"
+}
+
+func (d *DaemonPodMonitor) Informer(sif informers.SharedInformerFactory) cache.SharedInformer {
+	return sif.Core().V1().Pods().Informer()
+}
+
+func (d *DaemonPodMonitor) ValidateResource(obj interface{}) (string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return "", errors.New("failed to get pod from provided object")
+	}
+
+	objectId := fmt.Sprintf("v1.pod/%s/%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+
+	if _, ok := pod.Annotations[PodHostnameAnnotation]; !ok {
+		return objectId, fmt.Errorf("skipping pod (%s) because it doesn't have a hostname annotation", objectId)
+	}
+
+	if pod.ObjectMeta.DeletionTimestamp != nil {
+		return objectId, fmt.Errorf("skipping pod (%s) because it is terminating", objectId)
+	}
+
+	if !podIsReady(pod) {
+		return objectId, fmt.Errorf("skipping pod (%s) because it isn't ready", objectId)
+	}
+
+	if len(podIps(pod)) == 0 {
+		return objectId, fmt.Errorf("skipping pod (%s) because it doesn't have an IP", objectId)
+	}
+
+	return objectId, nil
+}
+
+func (d *DaemonPodMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		panic("Failed to get pod from pre-validated object.")
+	}
+
+	hostnames := []string{}
+	for _, name := range strings.Split(pod.Annotations[PodHostnameAnnotation], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		hostnames = append(hostnames, qualifyHostname(name, d.searchDomain))
+	}
+
+	entries := []hostsfile.HostsEntry{}
+	for _, ip := range podIps(pod) {
+		he := hostsfile.NewHostsEntry(ip, hostnames)
+		entries = append(entries, *he)
+	}
+
+	return entries
+}
+
+func podIsReady(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// Older clusters only populate PodIP, so fall back to it if PodIPs is empty.
+func podIps(pod *v1.Pod) []string {
+	ips := []string{}
+	for _, podIp := range pod.Status.PodIPs {
+		ips = append(ips, podIp.IP)
+	}
+
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+
+	return ips
+}
@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func validTestPod() *v1.Pod {
+	pod := v1.Pod{}
+	pod.ObjectMeta.Namespace = "default"
+	pod.ObjectMeta.Name = "some-pod"
+	pod.Annotations = make(map[string]string)
+	pod.Annotations[PodHostnameAnnotation] = "some-pod"
+
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.Conditions = []v1.PodCondition{
+		v1.PodCondition{Type: v1.PodReady, Status: v1.ConditionTrue},
+	}
+	pod.Status.PodIP = "192.168.1.20"
+	pod.Status.PodIPs = []v1.PodIP{
+		v1.PodIP{IP: "192.168.1.20"},
+		v1.PodIP{IP: "fd00::20"},
+	}
+
+	return &pod
+}
+
+func TestDaemonPodMonitorName(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	assert.Equal(t, "pod", drm.Name())
+}
+
+func TestDaemonPodMonitorValidateResource(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	pod := validTestPod()
+
+	objectId, err := drm.ValidateResource(pod)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+}
+
+func TestDaemonPodMonitorValidateResourceNotPod(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	objectId, err := drm.ValidateResource(&drm)
+	assert.Equal(t, "failed to get pod from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonPodMonitorValidateResourceNoAnnotation(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	pod := validTestPod()
+	delete(pod.Annotations, PodHostnameAnnotation)
+
+	objectId, err := drm.ValidateResource(pod)
+	assert.Equal(t, "skipping pod (v1.pod/default/some-pod) because it doesn't have a hostname annotation", err.Error())
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+}
+
+func TestDaemonPodMonitorValidateResourceTerminating(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	pod := validTestPod()
+	now := metav1.Now()
+	pod.ObjectMeta.DeletionTimestamp = &now
+
+	objectId, err := drm.ValidateResource(pod)
+	assert.Equal(t, "skipping pod (v1.pod/default/some-pod) because it is terminating", err.Error())
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+}
+
+func TestDaemonPodMonitorValidateResourceNotReady(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	pod := validTestPod()
+	pod.Status.Conditions[0].Status = v1.ConditionFalse
+
+	objectId, err := drm.ValidateResource(pod)
+	assert.Equal(t, "skipping pod (v1.pod/default/some-pod) because it isn't ready", err.Error())
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+
+	pod = validTestPod()
+	pod.Status.Phase = v1.PodSucceeded
+
+	objectId, err = drm.ValidateResource(pod)
+	assert.Equal(t, "skipping pod (v1.pod/default/some-pod) because it isn't ready", err.Error())
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+}
+
+func TestDaemonPodMonitorValidateResourceNoIp(t *testing.T) {
+	drm := DaemonPodMonitor{}
+
+	pod := validTestPod()
+	pod.Status.PodIP = ""
+	pod.Status.PodIPs = nil
+
+	objectId, err := drm.ValidateResource(pod)
+	assert.Equal(t, "skipping pod (v1.pod/default/some-pod) because it doesn't have an IP", err.Error())
+	assert.Equal(t, "v1.pod/default/some-pod", objectId)
+}
+
+func TestDaemonPodMonitorGetResourceHostsEntries(t *testing.T) {
+	drm := DaemonPodMonitor{"internal.aleemhaji.com"}
+
+	pod := validTestPod()
+
+	e1 := hostsfile.NewHostsEntry("192.168.1.20", []string{"some-pod.internal.aleemhaji.com."})
+	e2 := hostsfile.NewHostsEntry("fd00::20", []string{"some-pod.internal.aleemhaji.com."})
+	he := drm.GetResourceHostsEntries(pod)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1, *e2}, he)
+
+	pod.Annotations[PodHostnameAnnotation] = "home-assistant.internal.aleemhaji.com, hass"
+	pod.Status.PodIPs = nil
+
+	e1 = hostsfile.NewHostsEntry("192.168.1.20", []string{"home-assistant.internal.aleemhaji.com.", "hass.internal.aleemhaji.com."})
+	he = drm.GetResourceHostsEntries(pod)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1}, he)
+}
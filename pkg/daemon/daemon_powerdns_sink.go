@@ -0,0 +1,217 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Url is the PowerDNS API, e.g. http://powerdns.internal:8081, and ServerId
+// the server the zone lives on, which defaults to localhost.
+// Names outside of Zone are left out.
+// StateFile lists the names and types of the RRsets that were replaced, so
+// ones that stop being published while the daemon isn't running are deleted
+// once it's back.
+type PowerDNSOptions struct {
+	Url       string
+	ApiKey    string
+	ServerId  string
+	Zone      string
+	Ttl       uint32
+	StateFile string
+}
+
+// Manages RRsets in a PowerDNS zone through its HTTP API.
+// Published names are taken over: the first update replaces every RRset
+// being published, whether the sink created it or it was already in the
+// zone, so leftovers from a previous run are cleared out, but so are records
+// added by hand. Every replaced RRset is then the sink's own, and is deleted
+// once its name and type are no longer published. After the first update,
+// only RRsets that changed are replaced. RRsets at names and types that were
+// never published are never touched.
+type DaemonPowerDNSSink struct {
+	options PowerDNSOptions
+	client  *http.Client
+	owned   *ownedRecords
+
+	synced bool
+	rrsets map[string]powerDNSRRset
+}
+
+type powerDNSRRset struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	Ttl        uint32           `json:"ttl,omitempty"`
+	ChangeType string           `json:"changetype"`
+	Records    []powerDNSRecord `json:"records,omitempty"`
+}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+func NewDaemonPowerDNSSink(options PowerDNSOptions) (*DaemonPowerDNSSink, error) {
+	if options.Url == "" {
+		return nil, errors.New("powerdns requires a url")
+	}
+	options.Url = strings.TrimSuffix(options.Url, "/")
+
+	options.Zone = dns.Fqdn(strings.ToLower(options.Zone))
+	if options.Zone == "." {
+		return nil, errors.New("powerdns requires a zone")
+	}
+
+	if options.ServerId == "" {
+		options.ServerId = "localhost"
+	}
+
+	if options.Ttl == 0 {
+		options.Ttl = 300
+	}
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DaemonPowerDNSSink{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		owned:   owned,
+		rrsets:  map[string]powerDNSRRset{},
+	}
+	return &d, nil
+}
+
+func (d *DaemonPowerDNSSink) Name() string {
+	return fmt.Sprintf("powerdns zone %s", d.options.Zone)
+}
+
+func (d *DaemonPowerDNSSink) Update(snapshot hostsfile.Snapshot) error {
+	rrsets := powerDNSRRsets(dnsserver.ZoneRecords(d.options.Zone, d.options.Ttl, snapshot))
+
+	changes := []powerDNSRRset{}
+	for key, rrset := range rrsets {
+		if previous, ok := d.rrsets[key]; !d.synced || !ok || !powerDNSRRsetsEqual(previous, rrset) {
+			changes = append(changes, rrset)
+		}
+	}
+	deleted := []string{}
+	for _, key := range d.owned.Keys() {
+		if _, ok := rrsets[key]; !ok {
+			parts := strings.SplitN(key, " ", 2)
+			changes = append(changes, powerDNSRRset{Name: parts[0], Type: parts[1], ChangeType: "DELETE"})
+			deleted = append(deleted, key)
+		}
+	}
+
+	if len(changes) != 0 {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Name != changes[j].Name {
+				return changes[i].Name < changes[j].Name
+			}
+			return changes[i].Type < changes[j].Type
+		})
+
+		if err := d.patch(changes); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range deleted {
+		d.owned.Remove(key)
+	}
+	for key := range rrsets {
+		d.owned.Add(key)
+	}
+
+	d.synced = true
+	d.rrsets = rrsets
+	return d.owned.Save()
+}
+
+func (d *DaemonPowerDNSSink) patch(changes []powerDNSRRset) error {
+	body, err := json.Marshal(map[string][]powerDNSRRset{"rrsets": changes})
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/api/v1/servers/%s/zones/%s", url.PathEscape(d.options.ServerId), url.PathEscape(d.options.Zone))
+	req, err := http.NewRequest(http.MethodPatch, d.options.Url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", d.options.ApiKey)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		contents, _ := io.ReadAll(resp.Body)
+		apiError := struct {
+			Error string `json:"error"`
+		}{}
+		if json.Unmarshal(contents, &apiError) == nil && apiError.Error != "" {
+			return fmt.Errorf("powerdns update of %s failed: %s", d.options.Zone, apiError.Error)
+		}
+		return fmt.Errorf("powerdns update of %s failed: %s", d.options.Zone, resp.Status)
+	}
+
+	return nil
+}
+
+// Records are grouped into REPLACE RRsets by name and type, with their
+// contents as they'd be written in a zone file.
+func powerDNSRRsets(rrs []dns.RR) map[string]powerDNSRRset {
+	rrsets := map[string]powerDNSRRset{}
+	for _, rr := range rrs {
+		h := rr.Header()
+		rtype := dns.TypeToString[h.Rrtype]
+		key := fmt.Sprintf("%s %s", h.Name, rtype)
+
+		rrset, ok := rrsets[key]
+		if !ok {
+			rrset = powerDNSRRset{Name: h.Name, Type: rtype, Ttl: h.Ttl, ChangeType: "REPLACE"}
+		}
+		rrset.Records = append(rrset.Records, powerDNSRecord{Content: strings.TrimPrefix(rr.String(), h.String())})
+		rrsets[key] = rrset
+	}
+
+	return rrsets
+}
+
+func powerDNSRRsetsEqual(a, b powerDNSRRset) bool {
+	if a.Ttl != b.Ttl || len(a.Records) != len(b.Records) {
+		return false
+	}
+
+	for i := range a.Records {
+		if a.Records[i] != b.Records[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Applies PATCHes to a single zone's RRsets, the way PowerDNS would.
+type testPowerDNSAPI struct {
+	lock    sync.Mutex
+	rrsets  map[string][]string
+	patches [][]powerDNSRRset
+}
+
+func (p *testPowerDNSAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/servers/localhost/zones/internal.aleemhaji.com." {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error": "Could not find domain"}`))
+		return
+	}
+
+	body := map[string][]powerDNSRRset{}
+	json.NewDecoder(r.Body).Decode(&body)
+	p.patches = append(p.patches, body["rrsets"])
+	for _, rrset := range body["rrsets"] {
+		key := rrset.Name + " " + rrset.Type
+		if rrset.ChangeType == "DELETE" {
+			delete(p.rrsets, key)
+			continue
+		}
+
+		contents := []string{}
+		for _, record := range rrset.Records {
+			contents = append(contents, record.Content)
+		}
+		p.rrsets[key] = contents
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *testPowerDNSAPI) Keys() []string {
+	keys := []string{}
+	for key := range p.rrsets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func testPowerDNSServer(t *testing.T) (*testPowerDNSAPI, string) {
+	p := &testPowerDNSAPI{
+		rrsets: map[string][]string{
+			"mail.internal.aleemhaji.com. A": []string{"192.168.1.25"},
+		},
+	}
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	return p, server.URL
+}
+
+func TestNewDaemonPowerDNSSink(t *testing.T) {
+	_, err := NewDaemonPowerDNSSink(PowerDNSOptions{Zone: "internal.aleemhaji.com"})
+	assert.Equal(t, "powerdns requires a url", err.Error())
+
+	_, err = NewDaemonPowerDNSSink(PowerDNSOptions{Url: "http://powerdns.internal:8081"})
+	assert.Equal(t, "powerdns requires a zone", err.Error())
+
+	d, err := NewDaemonPowerDNSSink(PowerDNSOptions{Url: "http://powerdns.internal:8081/", Zone: "Internal.aleemhaji.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "powerdns zone internal.aleemhaji.com.", d.Name())
+	assert.Equal(t, "localhost", d.options.ServerId)
+	assert.Equal(t, uint32(300), d.options.Ttl)
+}
+
+func TestDaemonPowerDNSSinkUpdate(t *testing.T) {
+	p, serverUrl := testPowerDNSServer(t)
+
+	d, err := NewDaemonPowerDNSSink(PowerDNSOptions{Url: serverUrl, ApiKey: "secret", Zone: "internal.aleemhaji.com", Ttl: 60})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com", "google.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com", "other-service.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewSRVRecord("_http._tcp.some-service.internal.aleemhaji.com.", 0, 0, 80, "some-service.internal.aleemhaji.com."),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []powerDNSRRset{
+		{"_http._tcp.some-service.internal.aleemhaji.com.", "SRV", 60, "REPLACE", []powerDNSRecord{{"0 0 80 some-service.internal.aleemhaji.com.", false}}},
+		{"other-service.internal.aleemhaji.com.", "A", 60, "REPLACE", []powerDNSRecord{{"192.168.1.3", false}}},
+		{"some-service.internal.aleemhaji.com.", "A", 60, "REPLACE", []powerDNSRecord{{"192.168.1.2", false}, {"192.168.1.3", false}}},
+	}, p.patches[0])
+	assert.Equal(t, []string{
+		"_http._tcp.some-service.internal.aleemhaji.com. SRV",
+		"mail.internal.aleemhaji.com. A",
+		"other-service.internal.aleemhaji.com. A",
+		"some-service.internal.aleemhaji.com. A",
+	}, p.Keys())
+
+	// Nothing changed, so nothing is sent.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 1, len(p.patches))
+
+	// Only the names that changed are sent.
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []powerDNSRRset{
+		{"other-service.internal.aleemhaji.com.", "A", 0, "DELETE", nil},
+		{"some-service.internal.aleemhaji.com.", "A", 60, "REPLACE", []powerDNSRecord{{"192.168.1.2", false}}},
+	}, p.patches[1])
+	assert.Equal(t, []string{
+		"_http._tcp.some-service.internal.aleemhaji.com. SRV",
+		"mail.internal.aleemhaji.com. A",
+		"some-service.internal.aleemhaji.com. A",
+	}, p.Keys())
+}
+
+func TestDaemonPowerDNSSinkUpdateStateFile(t *testing.T) {
+	p, serverUrl := testPowerDNSServer(t)
+	p.rrsets["stale.internal.aleemhaji.com. A"] = []string{"192.168.1.9"}
+
+	stateFile := filepath.Join(t.TempDir(), "powerdns.json")
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`["stale.internal.aleemhaji.com. A"]`), 0644))
+
+	d, err := NewDaemonPowerDNSSink(PowerDNSOptions{Url: serverUrl, ApiKey: "secret", Zone: "internal.aleemhaji.com", Ttl: 60, StateFile: stateFile})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+
+	// RRsets created by a previous run that aren't published anymore are
+	// deleted, but RRsets created some other way are left alone.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []powerDNSRRset{
+		{"some-service.internal.aleemhaji.com.", "A", 60, "REPLACE", []powerDNSRecord{{"192.168.1.2", false}}},
+		{"stale.internal.aleemhaji.com.", "A", 0, "DELETE", nil},
+	}, p.patches[0])
+	assert.Equal(t, []string{
+		"mail.internal.aleemhaji.com. A",
+		"some-service.internal.aleemhaji.com. A",
+	}, p.Keys())
+
+	contents, err := os.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, `["some-service.internal.aleemhaji.com. A"]`, string(contents))
+}
+
+func TestDaemonPowerDNSSinkUpdateFailed(t *testing.T) {
+	_, serverUrl := testPowerDNSServer(t)
+
+	d, err := NewDaemonPowerDNSSink(PowerDNSOptions{Url: serverUrl, ApiKey: "secret", Zone: "missing.aleemhaji.com"})
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.missing.aleemhaji.com"}),
+	})
+
+	err = d.Update(hf.Snapshot())
+	assert.Equal(t, "powerdns update of missing.aleemhaji.com. failed: Could not find domain", err.Error())
+	assert.False(t, d.synced)
+
+	d.options.ApiKey = "wrong"
+	err = d.Update(hf.Snapshot())
+	assert.Equal(t, "powerdns update of missing.aleemhaji.com. failed: 401 Unauthorized", err.Error())
+}
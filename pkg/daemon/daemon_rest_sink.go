@@ -0,0 +1,314 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// How to add and remove a single record.
+// Url and Body are text/templates executed with a RESTRecord, and can use the
+// helpers in hostsfile.TemplateFuncs, along with json, which quotes a string
+// for use in a JSON body. Requests without a body template don't send one.
+type RESTRequest struct {
+	Method string
+	Url    string
+	Body   string
+}
+
+// Headers are templates too, so the token can be put wherever the API
+// expects it. The token is read from TokenFile before every update, so it can
+// be rotated without a restart.
+// Names outside of Zone are left out, if it's given.
+// There's no way to list what an arbitrary API holds, so StateFile is the
+// only record of what was added: it's how records that stopped being
+// published while the daemon wasn't running get removed, and how records
+// avoid being added a second time. It's required, since many APIs reject
+// duplicates.
+type RESTOptions struct {
+	Add     RESTRequest
+	Remove  RESTRequest
+	Headers map[string]string
+
+	TokenFile string
+	Zone      string
+	Ttl       uint32
+	StateFile string
+}
+
+// What each request's templates are executed with.
+// Names are given without a trailing dot. Value holds what's usually in an
+// API's value field: the address of A and AAAA records, the target of CNAME
+// records, the priority, weight, port and target of SRV records, and the text
+// of TXT records.
+type RESTRecord struct {
+	Name  string
+	Type  string
+	Value string
+	Ttl   uint32
+	Zone  string
+	Token string
+
+	Target   string
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+}
+
+// Sends an add or remove request to an HTTP API for every record that was
+// added or removed since the last update, as described by request templates.
+// This covers APIs like Technitium's, and other home-lab and router APIs,
+// without needing a sink for each of them.
+type DaemonRESTSink struct {
+	options RESTOptions
+	client  *http.Client
+	owned   *ownedRecords
+
+	add     restTemplates
+	remove  restTemplates
+	headers map[string]*template.Template
+}
+
+type restTemplates struct {
+	method string
+	url    *template.Template
+	body   *template.Template
+}
+
+var restTemplateFuncs template.FuncMap = template.FuncMap{
+	"json": func(s string) (string, error) {
+		rv, err := json.Marshal(s)
+		return string(rv), err
+	},
+}
+
+func NewDaemonRESTSink(options RESTOptions) (*DaemonRESTSink, error) {
+	if options.Add.Url == "" || options.Remove.Url == "" {
+		return nil, errors.New("rest sink requires add and remove urls")
+	}
+
+	if options.StateFile == "" {
+		return nil, errors.New("rest sink requires a state file")
+	}
+
+	if options.Ttl == 0 {
+		options.Ttl = 300
+	}
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	add, err := parseRESTRequest("add", options.Add, http.MethodPost)
+	if err != nil {
+		return nil, err
+	}
+
+	remove, err := parseRESTRequest("remove", options.Remove, http.MethodDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]*template.Template{}
+	for name, value := range options.Headers {
+		t, err := parseRESTTemplate("header "+name, value)
+		if err != nil {
+			return nil, err
+		}
+		headers[name] = t
+	}
+
+	d := DaemonRESTSink{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		owned:   owned,
+		add:     add,
+		remove:  remove,
+		headers: headers,
+	}
+	return &d, nil
+}
+
+func (d *DaemonRESTSink) Name() string {
+	return fmt.Sprintf("rest api at %s", d.options.Add.Url)
+}
+
+// Progress is saved even when a request fails, so records that were added
+// before it aren't added again.
+func (d *DaemonRESTSink) Update(snapshot hostsfile.Snapshot) error {
+	token := ""
+	if d.options.TokenFile != "" {
+		contents, err := os.ReadFile(d.options.TokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(contents))
+	}
+
+	wanted := map[string]bool{}
+	records := dnsserver.ZoneRecords(d.options.Zone, d.options.Ttl, snapshot)
+	for _, rr := range records {
+		wanted[rr.String()] = true
+	}
+
+	err := d.apply(records, wanted, token)
+	if saveErr := d.owned.Save(); err == nil {
+		err = saveErr
+	}
+
+	return err
+}
+
+func (d *DaemonRESTSink) apply(records []dns.RR, wanted map[string]bool, token string) error {
+	for _, key := range d.owned.Keys() {
+		if wanted[key] {
+			continue
+		}
+
+		rr, err := dns.NewRR(key)
+		if err != nil {
+			return err
+		}
+
+		if err := d.send(d.remove, d.record(rr, token)); err != nil {
+			return err
+		}
+		d.owned.Remove(key)
+	}
+
+	for _, rr := range records {
+		if d.owned.Owns(rr.String()) {
+			continue
+		}
+
+		if err := d.send(d.add, d.record(rr, token)); err != nil {
+			return err
+		}
+		d.owned.Add(rr.String())
+	}
+
+	return nil
+}
+
+func (d *DaemonRESTSink) send(request restTemplates, record RESTRecord) error {
+	url, err := executeRESTTemplate(request.url, record)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if request.body != nil {
+		contents, err := executeRESTTemplate(request.body, record)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(contents)
+	}
+
+	req, err := http.NewRequest(request.method, url, body)
+	if err != nil {
+		return err
+	}
+
+	for name, t := range d.headers {
+		value, err := executeRESTTemplate(t, record)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		contents, _ := io.ReadAll(resp.Body)
+		if message := strings.TrimSpace(string(contents)); message != "" {
+			return fmt.Errorf("rest api %s of %s %s failed: %s: %s", request.method, record.Name, record.Type, resp.Status, message)
+		}
+		return fmt.Errorf("rest api %s of %s %s failed: %s", request.method, record.Name, record.Type, resp.Status)
+	}
+
+	return nil
+}
+
+func (d *DaemonRESTSink) record(rr dns.RR, token string) RESTRecord {
+	h := rr.Header()
+	record := RESTRecord{
+		Name:  strings.TrimSuffix(h.Name, "."),
+		Type:  dns.TypeToString[h.Rrtype],
+		Ttl:   h.Ttl,
+		Zone:  strings.TrimSuffix(d.options.Zone, "."),
+		Token: token,
+	}
+
+	switch r := rr.(type) {
+	case *dns.A:
+		record.Value = r.A.String()
+	case *dns.AAAA:
+		record.Value = r.AAAA.String()
+	case *dns.CNAME:
+		record.Target = strings.TrimSuffix(r.Target, ".")
+		record.Value = record.Target
+	case *dns.SRV:
+		record.Target = strings.TrimSuffix(r.Target, ".")
+		record.Priority, record.Weight, record.Port = r.Priority, r.Weight, r.Port
+		record.Value = fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, record.Target)
+	case *dns.TXT:
+		record.Value = strings.Join(r.Txt, "")
+	}
+
+	return record
+}
+
+func parseRESTRequest(name string, request RESTRequest, method string) (restTemplates, error) {
+	templates := restTemplates{method: request.Method}
+	if templates.method == "" {
+		templates.method = method
+	}
+
+	var err error
+	if templates.url, err = parseRESTTemplate(name+" url", request.Url); err != nil {
+		return templates, err
+	}
+
+	if request.Body != "" {
+		if templates.body, err = parseRESTTemplate(name+" body", request.Body); err != nil {
+			return templates, err
+		}
+	}
+
+	return templates, nil
+}
+
+func parseRESTTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(hostsfile.TemplateFuncs).Funcs(restTemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+func executeRESTTemplate(t *template.Template, record RESTRecord) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, record); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
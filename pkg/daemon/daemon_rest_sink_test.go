@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Logs every request it gets, and fails the ones it's told to.
+type testRESTAPI struct {
+	lock     sync.Mutex
+	requests []string
+	fail     string
+}
+
+func (a *testRESTAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	request := r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("Authorization") + " " + string(body)
+	if a.fail != "" && a.fail == r.URL.Query().Get("domain") {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("record already exists\n"))
+		return
+	}
+
+	a.requests = append(a.requests, request)
+}
+
+func testRESTServer(t *testing.T) (*testRESTAPI, string) {
+	a := &testRESTAPI{}
+
+	server := httptest.NewServer(a)
+	t.Cleanup(server.Close)
+
+	return a, server.URL
+}
+
+func testRESTOptions(t *testing.T, serverUrl string) RESTOptions {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, os.WriteFile(tokenFile, []byte("abc123\n"), 0600))
+
+	return RESTOptions{
+		Add: RESTRequest{
+			Url:  serverUrl + "/api/zones/records/add?zone={{ .Zone }}&domain={{ .Name | urlquery }}&type={{ .Type }}&ttl={{ .Ttl }}",
+			Body: `{"value": {{ json .Value }}}`,
+		},
+		Remove: RESTRequest{
+			Method: http.MethodPost,
+			Url:    serverUrl + "/api/zones/records/delete?zone={{ .Zone }}&domain={{ .Name | urlquery }}&type={{ .Type }}",
+		},
+		Headers: map[string]string{
+			"Authorization": "Bearer {{ .Token }}",
+		},
+		TokenFile: tokenFile,
+		Zone:      "internal.aleemhaji.com",
+		Ttl:       60,
+		StateFile: filepath.Join(t.TempDir(), "owned.json"),
+	}
+}
+
+func TestNewDaemonRESTSink(t *testing.T) {
+	_, err := NewDaemonRESTSink(RESTOptions{Add: RESTRequest{Url: "http://dns.internal/add"}})
+	assert.Equal(t, "rest sink requires add and remove urls", err.Error())
+
+	_, err = NewDaemonRESTSink(RESTOptions{Add: RESTRequest{Url: "http://dns.internal/add"}, Remove: RESTRequest{Url: "http://dns.internal/remove"}})
+	assert.Equal(t, "rest sink requires a state file", err.Error())
+
+	stateFile := filepath.Join(t.TempDir(), "owned.json")
+	_, err = NewDaemonRESTSink(RESTOptions{Add: RESTRequest{Url: "http://dns.internal/add?{{ .Name"}, Remove: RESTRequest{Url: "http://dns.internal/remove"}, StateFile: stateFile})
+	assert.Equal(t, "template: add url:1: unclosed action", err.Error())
+
+	d, err := NewDaemonRESTSink(RESTOptions{Add: RESTRequest{Url: "http://dns.internal/add"}, Remove: RESTRequest{Url: "http://dns.internal/remove"}, StateFile: stateFile})
+	assert.Nil(t, err)
+	assert.Equal(t, "rest api at http://dns.internal/add", d.Name())
+	assert.Equal(t, http.MethodPost, d.add.method)
+	assert.Equal(t, http.MethodDelete, d.remove.method)
+	assert.Nil(t, d.add.body)
+}
+
+func TestDaemonRESTSinkUpdate(t *testing.T) {
+	a, serverUrl := testRESTServer(t)
+	options := testRESTOptions(t, serverUrl)
+
+	d, err := NewDaemonRESTSink(options)
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com", "google.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewSRVRecord("_http._tcp.some-service.internal.aleemhaji.com.", 0, 5, 80, "some-service.internal.aleemhaji.com."),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		`POST /api/zones/records/add?zone=internal.aleemhaji.com&domain=_http._tcp.some-service.internal.aleemhaji.com&type=SRV&ttl=60 Bearer abc123 {"value": "0 5 80 some-service.internal.aleemhaji.com"}`,
+		`POST /api/zones/records/add?zone=internal.aleemhaji.com&domain=some-service.internal.aleemhaji.com&type=A&ttl=60 Bearer abc123 {"value": "192.168.1.2"}`,
+	}, a.requests)
+
+	// Nothing changed, so nothing is sent.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 2, len(a.requests))
+
+	// Records that changed while the daemon wasn't running are picked up
+	// after a restart.
+	d, err = NewDaemonRESTSink(options)
+	assert.Nil(t, err)
+
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		`POST /api/zones/records/delete?zone=internal.aleemhaji.com&domain=some-service.internal.aleemhaji.com&type=A Bearer abc123 `,
+		`POST /api/zones/records/add?zone=internal.aleemhaji.com&domain=some-service.internal.aleemhaji.com&type=A&ttl=60 Bearer abc123 {"value": "192.168.1.3"}`,
+	}, a.requests[2:])
+}
+
+func TestDaemonRESTSinkUpdateFailed(t *testing.T) {
+	a, serverUrl := testRESTServer(t)
+	a.fail = "some-service.internal.aleemhaji.com"
+
+	d, err := NewDaemonRESTSink(testRESTOptions(t, serverUrl))
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"other-service.internal.aleemhaji.com", "some-service.internal.aleemhaji.com"}),
+	})
+
+	err = d.Update(hf.Snapshot())
+	assert.Equal(t, "rest api POST of some-service.internal.aleemhaji.com A failed: 400 Bad Request: record already exists", err.Error())
+
+	// Records that were added before the failure aren't added again.
+	a.fail = ""
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{
+		`POST /api/zones/records/add?zone=internal.aleemhaji.com&domain=other-service.internal.aleemhaji.com&type=A&ttl=60 Bearer abc123 {"value": "192.168.1.2"}`,
+		`POST /api/zones/records/add?zone=internal.aleemhaji.com&domain=some-service.internal.aleemhaji.com&type=A&ttl=60 Bearer abc123 {"value": "192.168.1.2"}`,
+	}, a.requests)
+}
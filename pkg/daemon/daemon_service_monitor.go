@@ -3,6 +3,7 @@ package daemon
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"k8s.io/api/core/v1"
 	"k8s.io/client-go/informers"
@@ -11,6 +12,9 @@ import (
 	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
 )
 
+// Comma separated names to publish as CNAMEs of the service's own hostname.
+const ServiceAliasesAnnotation string = "hostsfile-generator/aliases"
+
 type DaemonServiceMonitor struct {
 	searchDomain string
 }
@@ -38,13 +42,64 @@ func (d *DaemonServiceMonitor) ValidateResource(obj interface{}) (string, error)
 	return objectId, nil
 }
 
-func (d *DaemonServiceMonitor) GetResourceHostsEntry(obj interface{}) hostsfile.HostsEntry {
+// Load balancers that only give a hostname get a CNAME instead.
+func (d *DaemonServiceMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
 	service, ok := obj.(*v1.Service)
 	if !ok {
 		panic("Failed to get service from pre-validated object.")
 	}
 
+	if service.Spec.LoadBalancerIP == "" && loadBalancerHostname(service) != "" {
+		return []hostsfile.HostsEntry{}
+	}
+
 	fqdn := fmt.Sprintf("%s.%s.", service.ObjectMeta.Name, d.searchDomain)
 	he := hostsfile.NewHostsEntry(service.Spec.LoadBalancerIP, []string{fqdn})
-	return *he
+	return []hostsfile.HostsEntry{*he}
+}
+
+// CNAMEs for load balancer hostnames and aliases, and SRV records for named
+// ports.
+func (d *DaemonServiceMonitor) GetResourceRecords(obj interface{}) []hostsfile.Record {
+	service, ok := obj.(*v1.Service)
+	if !ok {
+		panic("Failed to get service from pre-validated object.")
+	}
+
+	fqdn := fmt.Sprintf("%s.%s.", service.ObjectMeta.Name, d.searchDomain)
+
+	records := []hostsfile.Record{}
+	if hostname := loadBalancerHostname(service); service.Spec.LoadBalancerIP == "" && hostname != "" {
+		records = append(records, *hostsfile.NewCNAMERecord(fqdn, hostname+"."))
+	}
+
+	for _, alias := range strings.Split(service.Annotations[ServiceAliasesAnnotation], ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		records = append(records, *hostsfile.NewCNAMERecord(qualifyHostname(alias, d.searchDomain), fqdn))
+	}
+
+	for _, port := range service.Spec.Ports {
+		if port.Name == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("_%s._%s.%s", port.Name, strings.ToLower(string(port.Protocol)), fqdn)
+		records = append(records, *hostsfile.NewSRVRecord(name, 0, 0, uint16(port.Port), fqdn))
+	}
+
+	return records
+}
+
+func loadBalancerHostname(service *v1.Service) string {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+	}
+
+	return ""
 }
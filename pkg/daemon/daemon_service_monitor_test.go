@@ -54,13 +54,50 @@ func TestDaemonServiceMonitorValidateResourceNotLoadBalancer(t *testing.T) {
 	assert.Equal(t, "v1.service/default/some-service", objectId)
 }
 
-func TestDaemonServiceMonitorGetResourceHostsEntry(t *testing.T) {
+func TestDaemonServiceMonitorGetResourceHostsEntries(t *testing.T) {
 	drm := DaemonServiceMonitor{"internal.aleemhaji.com"}
 
 	service := validTestService()
 
 	e := hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com."})
-	he := drm.GetResourceHostsEntry(service)
+	he := drm.GetResourceHostsEntries(service)
 
-	assert.Equal(t, *e, he)
+	assert.Equal(t, []hostsfile.HostsEntry{*e}, he)
+}
+
+func TestDaemonServiceMonitorGetResourceHostsEntriesHostname(t *testing.T) {
+	drm := DaemonServiceMonitor{"internal.aleemhaji.com"}
+
+	service := validTestService()
+	service.Spec.LoadBalancerIP = ""
+	service.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{
+		v1.LoadBalancerIngress{Hostname: "lb.example.com"},
+	}
+
+	assert.Equal(t, []hostsfile.HostsEntry{}, drm.GetResourceHostsEntries(service))
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("some-service.internal.aleemhaji.com.", "lb.example.com."),
+	}, drm.GetResourceRecords(service))
+}
+
+func TestDaemonServiceMonitorGetResourceRecords(t *testing.T) {
+	drm := DaemonServiceMonitor{"internal.aleemhaji.com"}
+
+	service := validTestService()
+	service.Annotations = map[string]string{ServiceAliasesAnnotation: "other, another.internal.aleemhaji.com,"}
+	service.Spec.Ports = []v1.ServicePort{
+		v1.ServicePort{Name: "http", Protocol: v1.ProtocolTCP, Port: 80},
+		v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443},
+		v1.ServicePort{Name: "dns", Protocol: v1.ProtocolUDP, Port: 53},
+	}
+	service.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{
+		v1.LoadBalancerIngress{Hostname: "lb.example.com"},
+	}
+
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("other.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewCNAMERecord("another.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewSRVRecord("_http._tcp.some-service.internal.aleemhaji.com.", 0, 0, 80, "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewSRVRecord("_dns._udp.some-service.internal.aleemhaji.com.", 0, 0, 53, "some-service.internal.aleemhaji.com."),
+	}, drm.GetResourceRecords(service))
 }
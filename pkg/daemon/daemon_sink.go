@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"strings"
+)
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Somewhere the published records get written to whenever they change.
+// Each sink renders the snapshot in whatever format its destination needs.
+type DaemonSink interface {
+	Name() string
+
+	Update(snapshot hostsfile.Snapshot) error
+}
+
+// Copies the rendered output into the Pi-hole pod, and restarts its DNS
+// service; without a pod, it's written to stdout instead.
+type DaemonPiholeSink struct {
+	restConfig *rest.Config
+	clientset  *kubernetes.Clientset
+	podName    string
+	renderer   hostsfile.Renderer
+}
+
+func (d *DaemonPiholeSink) Name() string {
+	return "pi-hole pod"
+}
+
+func (d *DaemonPiholeSink) Update(snapshot hostsfile.Snapshot) error {
+	contents, err := d.renderer.Render(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return WriteHostsFileAndRestartPihole(d.restConfig, d.clientset, d.podName, contents)
+}
+
+// Names as the web interfaces of DNS servers like Pi-hole and AdGuard Home
+// take them, without the trailing dot.
+func bareHostname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
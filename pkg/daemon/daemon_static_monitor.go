@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Object IDs of entries that don't come from a Kubernetes resource that's
+// published on its own are prefixed with this, so they can never collide with
+// those of the resource monitors.
+const StaticObjectIdPrefix string = "static/"
+
+// Publishes the hosts-format contents of a single key of a single ConfigMap.
+type DaemonStaticConfigMapMonitor struct {
+	namespace string
+	name      string
+	key       string
+}
+
+func (d *DaemonStaticConfigMapMonitor) Name() string {
+	return "static configmap"
+}
+
+// Only the one ConfigMap is watched, so the daemon only needs permission to
+// read ConfigMaps in its namespace.
+func (d *DaemonStaticConfigMapMonitor) Informer(sif informers.SharedInformerFactory) cache.SharedInformer {
+	return sif.InformerFor(&v1.ConfigMap{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+		return coreinformers.NewFilteredConfigMapInformer(client, d.namespace, resync, indexers, func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", d.name).String()
+		})
+	})
+}
+
+func (d *DaemonStaticConfigMapMonitor) ValidateResource(obj interface{}) (string, error) {
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		return "", errors.New("failed to get configmap from provided object")
+	}
+
+	if configMap.ObjectMeta.Namespace != d.namespace || configMap.ObjectMeta.Name != d.name {
+		return "", fmt.Errorf("skipping configmap (%s/%s) because it isn't the static hosts configmap", configMap.ObjectMeta.Namespace, configMap.ObjectMeta.Name)
+	}
+
+	objectId := fmt.Sprintf("%sconfigmap/%s/%s/%s", StaticObjectIdPrefix, d.namespace, d.name, d.key)
+
+	if _, ok := configMap.Data[d.key]; !ok {
+		return objectId, fmt.Errorf("skipping configmap (%s) because it doesn't have the key %s", objectId, d.key)
+	}
+
+	return objectId, nil
+}
+
+func (d *DaemonStaticConfigMapMonitor) GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry {
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		panic("Failed to get configmap from pre-validated object.")
+	}
+
+	// Lines that can't be used are skipped rather than failing the whole
+	// ConfigMap, so that one typo doesn't remove every static entry.
+	entries, err := hostsfile.Parse(strings.NewReader(configMap.Data[d.key]))
+	if err != nil {
+		log.Printf("Skipping invalid lines in static configmap %s/%s: %s\n", d.namespace, d.name, err.Error())
+	}
+
+	return entries
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func validTestStaticConfigMap() *v1.ConfigMap {
+	configMap := v1.ConfigMap{}
+	configMap.ObjectMeta.Namespace = "default"
+	configMap.ObjectMeta.Name = "static-hosts"
+	configMap.Data = map[string]string{
+		"hosts": "# Devices outside the cluster\n192.168.1.1\trouter.internal.aleemhaji.com\n\n192.168.1.30 nas.internal.aleemhaji.com files # The NAS\n192.168.1 broken\n",
+	}
+
+	return &configMap
+}
+
+func TestDaemonStaticConfigMapMonitorName(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{}
+
+	assert.Equal(t, "static configmap", drm.Name())
+}
+
+func TestDaemonStaticConfigMapMonitorValidateResource(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{"default", "static-hosts", "hosts"}
+
+	configMap := validTestStaticConfigMap()
+
+	objectId, err := drm.ValidateResource(configMap)
+	assert.Nil(t, err)
+	assert.Equal(t, "static/configmap/default/static-hosts/hosts", objectId)
+}
+
+func TestDaemonStaticConfigMapMonitorValidateResourceNotConfigMap(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{"default", "static-hosts", "hosts"}
+
+	objectId, err := drm.ValidateResource(&drm)
+	assert.Equal(t, "failed to get configmap from provided object", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonStaticConfigMapMonitorValidateResourceOtherConfigMap(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{"default", "static-hosts", "hosts"}
+
+	configMap := validTestStaticConfigMap()
+	configMap.ObjectMeta.Name = "something-else"
+
+	objectId, err := drm.ValidateResource(configMap)
+	assert.Equal(t, "skipping configmap (default/something-else) because it isn't the static hosts configmap", err.Error())
+	assert.Equal(t, "", objectId)
+}
+
+func TestDaemonStaticConfigMapMonitorValidateResourceNoKey(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{"default", "static-hosts", "static.list"}
+
+	configMap := validTestStaticConfigMap()
+
+	objectId, err := drm.ValidateResource(configMap)
+	assert.Equal(t, "skipping configmap (static/configmap/default/static-hosts/static.list) because it doesn't have the key static.list", err.Error())
+	assert.Equal(t, "static/configmap/default/static-hosts/static.list", objectId)
+}
+
+func TestDaemonStaticConfigMapMonitorGetResourceHostsEntries(t *testing.T) {
+	drm := DaemonStaticConfigMapMonitor{"default", "static-hosts", "hosts"}
+
+	configMap := validTestStaticConfigMap()
+
+	e1 := hostsfile.NewHostsEntry("192.168.1.1", []string{"router.internal.aleemhaji.com"})
+	e2 := hostsfile.NewHostsEntry("192.168.1.30", []string{"nas.internal.aleemhaji.com", "files"})
+	he := drm.GetResourceHostsEntries(configMap)
+	assert.Equal(t, []hostsfile.HostsEntry{*e1, *e2}, he)
+}
+
+func TestReadStaticFile(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+
+	path := filepath.Join(t.TempDir(), "static.list")
+	assert.Error(t, hfd.readStaticFile(path))
+	assert.Equal(t, 0, len(hfd.updatesChannel))
+
+	assert.NoError(t, os.WriteFile(path, []byte("192.168.1.30 nas\n"), 0644))
+	assert.NoError(t, hfd.readStaticFile(path))
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+
+	// Unchanged contents don't trigger another update.
+	assert.NoError(t, hfd.readStaticFile(path))
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+
+	assert.NoError(t, os.WriteFile(path, []byte("192.168.1.30 nas\n192.168.1 router\n"), 0644))
+	assert.EqualError(t, hfd.readStaticFile(path), "line 2, column 1: invalid IP address \"192.168.1\"")
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+
+	assert.NoError(t, os.Remove(path))
+	assert.Error(t, hfd.readStaticFile(path))
+	assert.Equal(t, 2, len(hfd.updatesChannel))
+	assert.False(t, hfd.hostsfile.RemoveHostsEntry("static/file/"+path))
+}
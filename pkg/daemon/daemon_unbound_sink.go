@@ -0,0 +1,225 @@
+package daemon
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Address is Unbound's remote control interface, e.g. 127.0.0.1:8953, or the
+// path of its control socket.
+// The certificates are the ones unbound-control-setup creates; without them,
+// the connection isn't encrypted, as with control-use-cert: no.
+// Names outside of Zone are left out.
+// StateFile names the domains whose local data the sink replaced, so the
+// first update after the daemon restarts can remove the ones no longer
+// published, without touching the rest of Unbound's local data.
+type UnboundOptions struct {
+	Address         string
+	ServerCertFile  string
+	ControlCertFile string
+	ControlKeyFile  string
+
+	Zone      string
+	Ttl       uint32
+	StateFile string
+}
+
+// Applies changes to a running Unbound's local data through its remote
+// control protocol, so it never has to reload.
+// The first update replaces the local data at every published name, and
+// removes the names the sink created that are no longer published; after
+// that, only names whose records changed are replaced. Local data at names
+// that aren't published, like the ones in unbound.conf, is never touched.
+// Unbound's local data can't hold wildcards, so they're left out.
+type DaemonUnboundSink struct {
+	options   UnboundOptions
+	tlsConfig *tls.Config
+	owned     *ownedRecords
+
+	synced bool
+	names  map[string][]string
+}
+
+func NewDaemonUnboundSink(options UnboundOptions) (*DaemonUnboundSink, error) {
+	if options.Address == "" {
+		return nil, errors.New("unbound requires a control address")
+	}
+
+	options.Zone = dns.Fqdn(strings.ToLower(options.Zone))
+	if options.Zone == "." {
+		return nil, errors.New("unbound requires a zone")
+	}
+
+	if options.Ttl == 0 {
+		options.Ttl = 300
+	}
+
+	owned, err := newOwnedRecords(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DaemonUnboundSink{options: options, owned: owned, names: map[string][]string{}}
+	if options.ServerCertFile == "" && options.ControlCertFile == "" && options.ControlKeyFile == "" {
+		return &d, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(options.ControlCertFile, options.ControlKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	serverCert, err := os.ReadFile(options.ServerCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(serverCert) {
+		return nil, fmt.Errorf("no certificates found in %s", options.ServerCertFile)
+	}
+
+	// unbound-control-setup always names the server's certificate unbound.
+	d.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   "unbound",
+	}
+	return &d, nil
+}
+
+func (d *DaemonUnboundSink) Name() string {
+	return fmt.Sprintf("unbound at %s", d.options.Address)
+}
+
+func (d *DaemonUnboundSink) Update(snapshot hostsfile.Snapshot) error {
+	names := map[string][]string{}
+	for _, rr := range dnsserver.ZoneRecords(d.options.Zone, d.options.Ttl, snapshot) {
+		name := rr.Header().Name
+		if !strings.HasPrefix(name, "*.") {
+			names[name] = append(names[name], rr.String())
+		}
+	}
+
+	removed, added := []string{}, []string{}
+	for _, name := range d.owned.Keys() {
+		if _, ok := names[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	for name, records := range names {
+		if previous, ok := d.names[name]; !ok || !d.synced || !stringsEqual(records, previous) {
+			removed = append(removed, name)
+			added = append(added, records...)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	if len(removed) != 0 {
+		if _, err := d.command("local_datas_remove", removed); err != nil {
+			return err
+		}
+	}
+
+	if len(added) != 0 {
+		if _, err := d.command("local_datas", added); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range removed {
+		d.owned.Remove(name)
+	}
+	for name := range names {
+		d.owned.Add(name)
+	}
+
+	d.synced = true
+	d.names = names
+	return d.owned.Save()
+}
+
+// Every command gets a connection of its own. Commands that take data, like
+// local_datas, read it a line at a time until a line holding only an
+// end-of-transmission character, the way unbound-control sends it.
+func (d *DaemonUnboundSink) command(command string, data []string) ([]string, error) {
+	conn, err := d.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("UBCT1 %s\n", command))
+	if data != nil {
+		for _, line := range data {
+			sb.WriteString(line + "\n")
+		}
+		sb.WriteString("\x04\n")
+	}
+
+	if _, err := io.WriteString(conn, sb.String()); err != nil {
+		return nil, err
+	}
+
+	output := []string{}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "error") {
+			return nil, fmt.Errorf("unbound %s failed: %s", command, line)
+		}
+		output = append(output, line)
+	}
+
+	return output, scanner.Err()
+}
+
+func (d *DaemonUnboundSink) dial() (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(d.options.Address, "/") {
+		network = "unix"
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if d.tlsConfig == nil {
+		return dialer.Dial(network, d.options.Address)
+	}
+
+	return tls.DialWithDialer(dialer, network, d.options.Address, d.tlsConfig)
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
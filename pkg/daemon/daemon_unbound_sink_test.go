@@ -0,0 +1,292 @@
+package daemon
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Answers remote control commands against in-memory local data, the way
+// Unbound would.
+type testUnboundControl struct {
+	lock     sync.Mutex
+	data     map[string][]string
+	commands []string
+}
+
+func (u *testUnboundControl) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	command := strings.TrimPrefix(strings.TrimSpace(line), "UBCT1 ")
+	// Unbound keeps reading data until the end-of-transmission line, and
+	// treats anything else, including an empty line, as data.
+	data := []string{}
+	if command == "local_datas" || command == "local_datas_remove" {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "\x04\n" {
+				break
+			}
+			data = append(data, strings.TrimSpace(line))
+		}
+	}
+	u.commands = append(u.commands, fmt.Sprintf("%s %d", command, len(data)))
+
+	switch command {
+	case "local_datas_remove":
+		for i, name := range data {
+			if _, ok := dns.IsDomainName(name); !ok || name == "" {
+				fmt.Fprintf(conn, "error for input line %d: bad name\n", i+1)
+				return
+			}
+			delete(u.data, name)
+		}
+		fmt.Fprintf(conn, "removed %d datas\n", len(data))
+	case "local_datas":
+		for i, line := range data {
+			rr, err := dns.NewRR(line)
+			if err != nil {
+				fmt.Fprintf(conn, "error for input line %d: %s\n", i+1, err.Error())
+				return
+			}
+			u.data[rr.Header().Name] = append(u.data[rr.Header().Name], rr.String())
+		}
+		fmt.Fprintf(conn, "added %d datas\n", len(data))
+	default:
+		fmt.Fprintf(conn, "error unknown command '%s'\n", command)
+	}
+}
+
+func (u *testUnboundControl) Names() []string {
+	names := []string{}
+	for name := range u.data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Creates certificates the way unbound-control-setup does, with a self-signed
+// server certificate that signs the control certificate.
+func testUnboundCertificates(t *testing.T) (string, tls.Certificate) {
+	dir := t.TempDir()
+
+	writePEM := func(name, blockType string, contents []byte) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: contents}), 0600))
+		return path
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serverTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "unbound"},
+		DNSNames:              []string{"unbound"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	serverDer, err := x509.CreateCertificate(rand.Reader, &serverTemplate, &serverTemplate, &serverKey.PublicKey, serverKey)
+	assert.Nil(t, err)
+	serverKeyDer, err := x509.MarshalECPrivateKey(serverKey)
+	assert.Nil(t, err)
+
+	controlKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	controlTemplate := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "unbound-control"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	controlDer, err := x509.CreateCertificate(rand.Reader, &controlTemplate, &serverTemplate, &controlKey.PublicKey, serverKey)
+	assert.Nil(t, err)
+	controlKeyDer, err := x509.MarshalECPrivateKey(controlKey)
+	assert.Nil(t, err)
+
+	writePEM("unbound_server.pem", "CERTIFICATE", serverDer)
+	writePEM("unbound_control.pem", "CERTIFICATE", controlDer)
+	writePEM("unbound_control.key", "EC PRIVATE KEY", controlKeyDer)
+
+	serverCert, err := tls.X509KeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDer}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: serverKeyDer}),
+	)
+	assert.Nil(t, err)
+
+	return dir, serverCert
+}
+
+func testUnboundServer(t *testing.T) (*testUnboundControl, UnboundOptions) {
+	dir, serverCert := testUnboundCertificates(t)
+
+	leaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	assert.Nil(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	u := &testUnboundControl{
+		data: map[string][]string{
+			"stale.internal.aleemhaji.com.":        []string{"stale.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9"},
+			"configured.internal.aleemhaji.com.":   []string{"configured.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.8"},
+			"some-service.internal.aleemhaji.com.": []string{"some-service.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.9"},
+			"router.lan.":                          []string{"router.lan.\t300\tIN\tA\t192.168.1.1"},
+		},
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go u.serve(conn)
+		}
+	}()
+
+	options := UnboundOptions{
+		Address:         l.Addr().String(),
+		ServerCertFile:  filepath.Join(dir, "unbound_server.pem"),
+		ControlCertFile: filepath.Join(dir, "unbound_control.pem"),
+		ControlKeyFile:  filepath.Join(dir, "unbound_control.key"),
+		Zone:            "internal.aleemhaji.com",
+		Ttl:             60,
+	}
+	return u, options
+}
+
+func TestNewDaemonUnboundSink(t *testing.T) {
+	_, err := NewDaemonUnboundSink(UnboundOptions{Zone: "internal.aleemhaji.com"})
+	assert.Equal(t, "unbound requires a control address", err.Error())
+
+	_, err = NewDaemonUnboundSink(UnboundOptions{Address: "127.0.0.1:8953"})
+	assert.Equal(t, "unbound requires a zone", err.Error())
+
+	d, err := NewDaemonUnboundSink(UnboundOptions{Address: "127.0.0.1:8953", Zone: "internal.aleemhaji.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "unbound at 127.0.0.1:8953", d.Name())
+	assert.Nil(t, d.tlsConfig)
+
+	_, err = NewDaemonUnboundSink(UnboundOptions{Address: "127.0.0.1:8953", Zone: "internal.aleemhaji.com", ServerCertFile: "/missing/unbound_server.pem"})
+	assert.NotNil(t, err)
+}
+
+func TestDaemonUnboundSinkUpdate(t *testing.T) {
+	u, options := testUnboundServer(t)
+	options.StateFile = filepath.Join(t.TempDir(), "unbound.json")
+	assert.Nil(t, os.WriteFile(options.StateFile, []byte(`["stale.internal.aleemhaji.com."]`), 0644))
+
+	d, err := NewDaemonUnboundSink(options)
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com", "*.apps.internal.aleemhaji.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"other-service.internal.aleemhaji.com"}),
+	})
+
+	// Every published name is replaced, and names the sink created before
+	// that aren't published anymore are removed. Everything else is left
+	// alone.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{"local_datas_remove 3", "local_datas 2"}, u.commands)
+	assert.Equal(t, map[string][]string{
+		"configured.internal.aleemhaji.com.":    []string{"configured.internal.aleemhaji.com.\t300\tIN\tA\t192.168.1.8"},
+		"other-service.internal.aleemhaji.com.": []string{"other-service.internal.aleemhaji.com.\t60\tIN\tA\t192.168.1.3"},
+		"some-service.internal.aleemhaji.com.":  []string{"some-service.internal.aleemhaji.com.\t60\tIN\tA\t192.168.1.2"},
+		"router.lan.":                           []string{"router.lan.\t300\tIN\tA\t192.168.1.1"},
+	}, u.data)
+
+	contents, err := os.ReadFile(options.StateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, `["other-service.internal.aleemhaji.com.","some-service.internal.aleemhaji.com."]`, string(contents))
+
+	// Nothing changed, so nothing is sent.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 2, len(u.commands))
+
+	// Only names that changed are replaced.
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.4", []string{"other-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{"local_datas_remove 1", "local_datas 1"}, u.commands[2:])
+	assert.Equal(t, []string{"other-service.internal.aleemhaji.com.\t60\tIN\tA\t192.168.1.4"}, u.data["other-service.internal.aleemhaji.com."])
+
+	// Names that stop being published are removed.
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, []string{"local_datas_remove 1"}, u.commands[4:])
+	assert.Equal(t, []string{"configured.internal.aleemhaji.com.", "router.lan.", "some-service.internal.aleemhaji.com."}, u.Names())
+}
+
+func TestDaemonUnboundSinkUpdateUntrusted(t *testing.T) {
+	_, options := testUnboundServer(t)
+	_, other := testUnboundServer(t)
+	options.ServerCertFile = other.ServerCertFile
+
+	d, err := NewDaemonUnboundSink(options)
+	assert.Nil(t, err)
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+
+	err = d.Update(hf.Snapshot())
+	assert.NotNil(t, err)
+	assert.False(t, d.synced)
+}
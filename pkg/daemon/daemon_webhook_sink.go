@@ -0,0 +1,199 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/dnsserver"
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+const WebhookSignatureHeader = "X-Hostsfile-Generator-Signature"
+
+// Url is POSTed the full record set, or only what changed if Diff is set.
+// With a Secret, the body is signed with HMAC-SHA256, and the signature sent
+// as "sha256=<hex>" in the WebhookSignatureHeader header.
+// Failed requests are retried Retries times, backing off exponentially;
+// requests the receiver rejected with a 4xx status, other than 429, aren't
+// retried.
+type WebhookOptions struct {
+	Url     string
+	Secret  string
+	Diff    bool
+	Version string
+	Retries int
+}
+
+// Sends the published records to an arbitrary HTTP endpoint whenever they
+// change.
+// The hash identifies the record set, so receivers can tell whether they've
+// missed a diff by comparing previousHash against the last hash they saw.
+type DaemonWebhookSink struct {
+	options WebhookOptions
+	client  *http.Client
+	backoff time.Duration
+
+	hash    string
+	records []dns.RR
+}
+
+type webhookPayload struct {
+	Version string          `json:"version,omitempty"`
+	Hash    string          `json:"hash"`
+	Records []webhookRecord `json:"records"`
+}
+
+// The first diff has an empty previous hash, and adds every record.
+type webhookDiffPayload struct {
+	Version      string          `json:"version,omitempty"`
+	Hash         string          `json:"hash"`
+	PreviousHash string          `json:"previousHash"`
+	Added        []webhookRecord `json:"added"`
+	Removed      []webhookRecord `json:"removed"`
+}
+
+// Data is written the way it would be in a zone file.
+type webhookRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+func NewDaemonWebhookSink(options WebhookOptions) (*DaemonWebhookSink, error) {
+	if options.Url == "" {
+		return nil, errors.New("webhook requires a url")
+	}
+
+	if options.Retries < 0 {
+		return nil, errors.New("webhook retries can't be negative")
+	}
+
+	d := DaemonWebhookSink{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: time.Second,
+	}
+	return &d, nil
+}
+
+func (d *DaemonWebhookSink) Name() string {
+	return fmt.Sprintf("webhook %s", d.options.Url)
+}
+
+func (d *DaemonWebhookSink) Update(snapshot hostsfile.Snapshot) error {
+	records := dnsserver.ZoneRecords(".", 0, snapshot)
+	hash := webhookHash(records)
+	if hash == d.hash {
+		return nil
+	}
+
+	var payload interface{} = webhookPayload{d.options.Version, hash, webhookRecords(records)}
+	if d.options.Diff {
+		removed, added := dnsserver.DiffRecords(d.records, records)
+		payload = webhookDiffPayload{d.options.Version, hash, d.hash, webhookRecords(added), webhookRecords(removed)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := d.post(body); err != nil {
+		return err
+	}
+
+	d.hash = hash
+	d.records = records
+	return nil
+}
+
+func (d *DaemonWebhookSink) post(body []byte) error {
+	backoff := d.backoff
+
+	var err error
+	for attempt := 0; attempt <= d.options.Retries; attempt++ {
+		if attempt != 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		if retry, err = d.attempt(body); err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Whether the request should be retried when it fails.
+func (d *DaemonWebhookSink) attempt(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, d.options.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if d.options.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(d.options.Secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook %s failed: %s", d.options.Url, resp.Status)
+	if contents, _ := io.ReadAll(resp.Body); strings.TrimSpace(string(contents)) != "" {
+		err = fmt.Errorf("webhook %s failed: %s: %s", d.options.Url, resp.Status, strings.TrimSpace(string(contents)))
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+func webhookRecords(rrs []dns.RR) []webhookRecord {
+	records := []webhookRecord{}
+	for _, rr := range rrs {
+		h := rr.Header()
+		records = append(records, webhookRecord{
+			Name: h.Name,
+			Type: dns.TypeToString[h.Rrtype],
+			Data: strings.TrimPrefix(rr.String(), h.String()),
+		})
+	}
+
+	return records
+}
+
+// Records are already sorted, so the same records always give the same hash.
+func webhookHash(rrs []dns.RR) string {
+	h := sha256.New()
+	for _, rr := range rrs {
+		fmt.Fprintln(h, rr.String())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Checks signatures, and fails with the statuses it's given before accepting
+// anything.
+type testWebhookReceiver struct {
+	lock     sync.Mutex
+	secret   string
+	statuses []int
+	attempts int
+	bodies   []map[string]interface{}
+}
+
+func (r *testWebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	mac := hmac.New(sha256.New, []byte(r.secret))
+	mac.Write(body)
+	if req.Header.Get(WebhookSignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("bad signature\n"))
+		return
+	}
+
+	r.attempts++
+	if len(r.statuses) != 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+		return
+	}
+
+	payload := map[string]interface{}{}
+	json.Unmarshal(body, &payload)
+	r.bodies = append(r.bodies, payload)
+}
+
+func testWebhookServer(t *testing.T, options WebhookOptions) (*testWebhookReceiver, *DaemonWebhookSink) {
+	r := &testWebhookReceiver{secret: "hunter2"}
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	options.Url = server.URL
+	d, err := NewDaemonWebhookSink(options)
+	assert.Nil(t, err)
+	d.backoff = 0
+
+	return r, d
+}
+
+func TestNewDaemonWebhookSink(t *testing.T) {
+	_, err := NewDaemonWebhookSink(WebhookOptions{})
+	assert.Equal(t, "webhook requires a url", err.Error())
+
+	_, err = NewDaemonWebhookSink(WebhookOptions{Url: "http://hooks.internal/dns", Retries: -1})
+	assert.Equal(t, "webhook retries can't be negative", err.Error())
+
+	d, err := NewDaemonWebhookSink(WebhookOptions{Url: "http://hooks.internal/dns"})
+	assert.Nil(t, err)
+	assert.Equal(t, "webhook http://hooks.internal/dns", d.Name())
+}
+
+func TestDaemonWebhookSinkUpdate(t *testing.T) {
+	r, d := testWebhookServer(t, WebhookOptions{Secret: "hunter2", Version: "v1.2.3"})
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, map[string]interface{}{
+		"version": "v1.2.3",
+		"hash":    d.hash,
+		"records": []interface{}{
+			map[string]interface{}{"name": "some-service.internal.aleemhaji.com.", "type": "A", "data": "192.168.1.2"},
+			map[string]interface{}{"name": "www.internal.aleemhaji.com.", "type": "CNAME", "data": "some-service.internal.aleemhaji.com."},
+		},
+	}, r.bodies[0])
+
+	// Nothing changed, so nothing is sent.
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 1, r.attempts)
+
+	assert.Nil(t, d.Update(hostsfile.Snapshot{}))
+	assert.Equal(t, []interface{}{}, r.bodies[1]["records"])
+}
+
+func TestDaemonWebhookSinkUpdateDiff(t *testing.T) {
+	r, d := testWebhookServer(t, WebhookOptions{Secret: "hunter2", Diff: true})
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+	})
+
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	firstHash := d.hash
+	assert.Equal(t, "", r.bodies[0]["previousHash"])
+	assert.Equal(t, 2, len(r.bodies[0]["added"].([]interface{})))
+	assert.Equal(t, []interface{}{}, r.bodies[0]["removed"])
+
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, map[string]interface{}{
+		"hash":         d.hash,
+		"previousHash": firstHash,
+		"added": []interface{}{
+			map[string]interface{}{"name": "some-service.internal.aleemhaji.com.", "type": "A", "data": "192.168.1.3"},
+		},
+		"removed": []interface{}{
+			map[string]interface{}{"name": "some-service.internal.aleemhaji.com.", "type": "A", "data": "192.168.1.2"},
+		},
+	}, r.bodies[1])
+}
+
+func TestDaemonWebhookSinkUpdateRetries(t *testing.T) {
+	r, d := testWebhookServer(t, WebhookOptions{Secret: "hunter2", Retries: 2})
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntry("abc", *hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}))
+
+	r.statuses = []int{http.StatusBadGateway, http.StatusTooManyRequests}
+	assert.Nil(t, d.Update(hf.Snapshot()))
+	assert.Equal(t, 3, r.attempts)
+	assert.Equal(t, 1, len(r.bodies))
+
+	hf.SetHostsEntry("abc", *hostsfile.NewHostsEntry("192.168.1.3", []string{"some-service.internal.aleemhaji.com"}))
+	r.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	err := d.Update(hf.Snapshot())
+	assert.Equal(t, "webhook "+d.options.Url+" failed: 502 Bad Gateway", err.Error())
+	assert.Equal(t, 6, r.attempts)
+
+	// Rejected requests aren't retried.
+	r.statuses = []int{http.StatusBadRequest}
+	err = d.Update(hf.Snapshot())
+	assert.Equal(t, "webhook "+d.options.Url+" failed: 400 Bad Request", err.Error())
+	assert.Equal(t, 7, r.attempts)
+}
+
+func TestDaemonWebhookSinkUpdateBadSignature(t *testing.T) {
+	_, d := testWebhookServer(t, WebhookOptions{Secret: "wrong"})
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntry("abc", *hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com"}))
+
+	err := d.Update(hf.Snapshot())
+	assert.Equal(t, "webhook "+d.options.Url+" failed: 401 Unauthorized: bad signature", err.Error())
+	assert.Equal(t, "", d.hash)
+}
@@ -5,13 +5,17 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
 import (
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 import (
@@ -25,19 +29,40 @@ type DaemonResourceMonitor interface {
 	Informer(sif informers.SharedInformerFactory) cache.SharedInformer
 
 	ValidateResource(obj interface{}) (string, error)
-	GetResourceHostsEntry(obj interface{}) hostsfile.HostsEntry
+	GetResourceHostsEntries(obj interface{}) []hostsfile.HostsEntry
+}
+
+// Monitors that can publish records other than addresses.
+type DaemonRecordMonitor interface {
+	GetResourceRecords(obj interface{}) []hostsfile.Record
 }
 
 type HostsFileDaemon struct {
 	config         DaemonConfig
 	hostsfile      hostsfile.IHostsFile
 	updatesChannel chan bool
+	sinks          []DaemonSink
+
+	// Sinks that failed their last update are retried on their own with the
+	//   snapshot they missed, until they succeed or the next update comes.
+	sinksLock         *sync.Mutex
+	failedSinks       []DaemonSink
+	failedSnapshot    hostsfile.Snapshot
+	sinkRetryInterval time.Duration
+	sinkRetryTimer    *time.Timer
+
+	// Objects are kept so that events can be recorded against them.
+	objectsLock       *sync.Mutex
+	objects           map[string]runtime.Object
+	recorder          record.EventRecorder
+	reportedConflicts map[string]bool
 }
 
 type IHostsFileDaemon interface {
 	Run()
 
 	Monitor(drm DaemonResourceMonitor)
+	WatchStaticFile(path string, interval time.Duration)
 
 	InformerAddFunc(drm DaemonResourceMonitor) func(obj interface{})
 	InformerDeleteFunc(drm DaemonResourceMonitor) func(obj interface{})
@@ -45,10 +70,30 @@ type IHostsFileDaemon interface {
 }
 
 func NewHostsFileDaemon(config DaemonConfig) *HostsFileDaemon {
+	chf := hostsfile.NewConcurrentHostsFile()
+	chf.SetConflictPolicy(config.ConflictPolicy)
+
+	renderer := config.Renderer
+	if renderer == nil {
+		renderer = &hostsfile.HostsRenderer{}
+	}
+
+	sinks := []DaemonSink{}
+	if !config.DisablePihole {
+		sinks = append(sinks, &DaemonPiholeSink{config.RestConfig, config.KubernetesClientSet, config.PiholePodName, renderer})
+	}
+	sinks = append(sinks, config.Sinks...)
+
 	hfd := HostsFileDaemon{
-		config,
-		hostsfile.NewConcurrentHostsFile(),
-		make(chan bool, 100),
+		config:            config,
+		hostsfile:         chf,
+		updatesChannel:    make(chan bool, 100),
+		sinks:             sinks,
+		sinksLock:         &sync.Mutex{},
+		sinkRetryInterval: time.Second * 60,
+		objectsLock:       &sync.Mutex{},
+		objects:           map[string]runtime.Object{},
+		reportedConflicts: map[string]bool{},
 	}
 	return &hfd
 }
@@ -65,6 +110,8 @@ func (hfd *HostsFileDaemon) Run() {
 	serverMajor, _ := strconv.Atoi(serverVersion.Major)
 	serverMinor, _ := strconv.Atoi(serverVersion.Minor)
 
+	hfd.recorder = NewEventRecorder(hfd.config.KubernetesClientSet)
+
 	go hfd.performUpdates()
 
 	// If the server is running a newer version of k8s, don't monitor
@@ -74,6 +121,24 @@ func (hfd *HostsFileDaemon) Run() {
 	}
 	go hfd.Monitor(&DaemonIngressMonitor{hfd.config.IngressIp, hfd.config.SearchDomain})
 	go hfd.Monitor(&DaemonServiceMonitor{hfd.config.SearchDomain})
+	if hfd.config.NodeHostnames {
+		go hfd.Monitor(&DaemonNodeMonitor{hfd.config.SearchDomain, hfd.config.NodeExternalIps})
+	}
+	if hfd.config.PodHostnames {
+		go hfd.Monitor(&DaemonPodMonitor{hfd.config.SearchDomain})
+	}
+	if hfd.config.HostsRecords {
+		go hfd.Monitor(&DaemonHostsRecordMonitor{hfd.config.DynamicClient, hfd.config.SearchDomain})
+	}
+	if hfd.config.DNSEndpoints {
+		go hfd.Monitor(&DaemonDNSEndpointMonitor{hfd.config.DynamicClient})
+	}
+	if hfd.config.StaticHostsConfigMapName != "" {
+		go hfd.Monitor(&DaemonStaticConfigMapMonitor{hfd.config.StaticHostsConfigMapNamespace, hfd.config.StaticHostsConfigMapName, hfd.config.StaticHostsConfigMapKey})
+	}
+	if hfd.config.StaticHostsFile != "" {
+		go hfd.WatchStaticFile(hfd.config.StaticHostsFile, time.Second*10)
+	}
 	go hfd.updateAfterInterval(time.Second * 60)
 
 	interrupt.WaitForAnySignal(syscall.SIGINT, syscall.SIGTERM)
@@ -96,6 +161,50 @@ func (hfd *HostsFileDaemon) Monitor(drm DaemonResourceMonitor) {
 	informerFactory.WaitForCacheSync(stop)
 }
 
+// Files can't be watched through the Kubernetes API, so just re-read it on an
+// interval; unchanged contents won't trigger an update.
+func (hfd *HostsFileDaemon) WatchStaticFile(path string, interval time.Duration) {
+	lastErr := ""
+	for {
+		errString := ""
+		if err := hfd.readStaticFile(path); err != nil {
+			// Only log when the error changes, rather than every interval.
+			errString = err.Error()
+			if errString != lastErr {
+				log.Printf("Failed to read static hosts file %s: %s\n", path, errString)
+			}
+		}
+
+		lastErr = errString
+		time.Sleep(interval)
+	}
+}
+
+// A file that can't be opened has its entries removed, the same way a resource
+// that fails validation does.
+func (hfd *HostsFileDaemon) readStaticFile(path string) error {
+	objectId := fmt.Sprintf("%sfile/%s", StaticObjectIdPrefix, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if hfd.hostsfile.RemoveHostsEntry(objectId) {
+			log.Printf("Removing outdated entry for static file: %s\n", objectId)
+			hfd.updatesChannel <- true
+		}
+		return err
+	}
+	defer f.Close()
+
+	// Lines that can't be parsed are skipped, but still reported.
+	entries, err := hostsfile.Parse(f)
+	if hfd.setHostsEntries("static file", objectId, entries) {
+		log.Printf("Updating entry for static file: %s\n", objectId)
+		hfd.updatesChannel <- true
+	}
+
+	return err
+}
+
 func (hfd *HostsFileDaemon) InformerAddFunc(drm DaemonResourceMonitor) func(obj interface{}) {
 	return func(obj interface{}) {
 		objectId, err := drm.ValidateResource(obj)
@@ -103,7 +212,9 @@ func (hfd *HostsFileDaemon) InformerAddFunc(drm DaemonResourceMonitor) func(obj
 			return
 		}
 
-		if hfd.hostsfile.SetHostsEntry(objectId, drm.GetResourceHostsEntry(obj)) {
+		updated := hfd.setHostsEntries(drm.Name(), objectId, drm.GetResourceHostsEntries(obj))
+		updated = hfd.setRecords(drm, objectId, obj) || updated
+		if hfd.setObject(objectId, obj) || updated {
 			log.Printf("Creating entry for %s: %s\n", drm.Name(), objectId)
 			hfd.updatesChannel <- true
 		}
@@ -112,11 +223,21 @@ func (hfd *HostsFileDaemon) InformerAddFunc(drm DaemonResourceMonitor) func(obj
 
 func (hfd *HostsFileDaemon) InformerDeleteFunc(drm DaemonResourceMonitor) func(obj interface{}) {
 	return func(obj interface{}) {
-		objectId, err := drm.ValidateResource(obj)
-		if err != nil {
+		// Deletes missed while the watch was down only come with the last
+		//   state of the object that was seen.
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+
+		// Objects are usually no longer valid by the time they're deleted,
+		//   like pods that are terminating, but whatever they published
+		//   still has to go.
+		objectId, _ := drm.ValidateResource(obj)
+		if objectId == "" {
 			return
 		}
 
+		hfd.forgetObject(objectId)
 		if hfd.hostsfile.RemoveHostsEntry(objectId) {
 			log.Printf("Remove entry for %s: %s\n", drm.Name(), objectId)
 			hfd.updatesChannel <- true
@@ -128,6 +249,7 @@ func (hfd *HostsFileDaemon) InformerUpdateFunc(drm DaemonResourceMonitor) func(o
 	return func(oldObj, newObj interface{}) {
 		objectId, err := drm.ValidateResource(newObj)
 		if err != nil {
+			hfd.forgetObject(objectId)
 			if objectId != "" && hfd.hostsfile.RemoveHostsEntry(objectId) {
 				log.Printf("Removing outdated entry %s: %s\n", drm.Name(), objectId)
 				hfd.updatesChannel <- true
@@ -135,13 +257,50 @@ func (hfd *HostsFileDaemon) InformerUpdateFunc(drm DaemonResourceMonitor) func(o
 			return
 		}
 
-		if hfd.hostsfile.SetHostsEntry(objectId, drm.GetResourceHostsEntry(newObj)) {
+		updated := hfd.setHostsEntries(drm.Name(), objectId, drm.GetResourceHostsEntries(newObj))
+		updated = hfd.setRecords(drm, objectId, newObj) || updated
+		if hfd.setObject(objectId, newObj) || updated {
 			log.Printf("Updating entry for %s: %s\n", drm.Name(), objectId)
 			hfd.updatesChannel <- true
 		}
 	}
 }
 
+// Drops anything that can't be published before storing the entries.
+// Reasons are only logged when the object's entries change; otherwise they'd
+// be repeated on every resync.
+func (hfd *HostsFileDaemon) setHostsEntries(name, objectId string, entries []hostsfile.HostsEntry) bool {
+	valid, errs := hostsfile.ValidateHostsEntries(entries, hfd.config.WildcardPolicy)
+
+	updated := hfd.hostsfile.SetHostsEntries(objectId, valid)
+	if updated {
+		for _, err := range errs {
+			log.Printf("Rejecting part of entry for %s: %s: %s\n", name, objectId, err.Error())
+		}
+	}
+
+	return updated
+}
+
+// Same as setHostsEntries, for monitors that publish other records too.
+func (hfd *HostsFileDaemon) setRecords(drm DaemonResourceMonitor, objectId string, obj interface{}) bool {
+	rm, ok := drm.(DaemonRecordMonitor)
+	if !ok {
+		return false
+	}
+
+	valid, errs := hostsfile.ValidateRecords(rm.GetResourceRecords(obj), hfd.config.WildcardPolicy)
+
+	updated := hfd.hostsfile.SetRecords(objectId, valid)
+	if updated {
+		for _, err := range errs {
+			log.Printf("Rejecting record for %s: %s: %s\n", drm.Name(), objectId, err.Error())
+		}
+	}
+
+	return updated
+}
+
 func (hfd *HostsFileDaemon) performUpdates() {
 	lastUpdate := time.Now()
 	for range hfd.updatesChannel {
@@ -152,15 +311,14 @@ func (hfd *HostsFileDaemon) performUpdates() {
 			continue
 		}
 
-		hostsfile := hfd.hostsfile.String()
+		hfd.reportConflicts()
+
+		snapshot := hfd.hostsfile.Snapshot()
 		// If the last update was more than 60 seconds ago, write this one
 		//   immediately
 		if time.Since(lastUpdate).Minutes() >= 1 {
 			log.Println("Last update was more than 1 minute ago. Updating immediately.")
-			err := WriteHostsFileAndRestartPihole(hfd.config.RestConfig, hfd.config.KubernetesClientSet, hfd.config.PiholePodName, hostsfile)
-			if err != nil {
-				log.Fatal(err)
-			}
+			hfd.updateSinks(snapshot)
 			lastUpdate = time.Now()
 			continue
 		}
@@ -173,16 +331,76 @@ func (hfd *HostsFileDaemon) performUpdates() {
 			continue
 		}
 
-		err := WriteHostsFileAndRestartPihole(hfd.config.RestConfig, hfd.config.KubernetesClientSet, hfd.config.PiholePodName, hostsfile)
-		if err != nil {
-			log.Fatal(err)
-		}
+		hfd.updateSinks(snapshot)
 		lastUpdate = time.Now()
 	}
 }
 
+// A sink failing doesn't stop the others from being updated; it's retried
+// later instead.
+func (hfd *HostsFileDaemon) updateSinks(snapshot hostsfile.Snapshot) {
+	hfd.sinksLock.Lock()
+	defer hfd.sinksLock.Unlock()
+
+	hfd.failedSinks = writeSinks(hfd.sinks, snapshot)
+	hfd.failedSnapshot = snapshot
+	hfd.scheduleSinkRetry()
+}
+
+func (hfd *HostsFileDaemon) retryFailedSinks() {
+	hfd.sinksLock.Lock()
+	defer hfd.sinksLock.Unlock()
+
+	if len(hfd.failedSinks) == 0 {
+		return
+	}
+
+	log.Printf("Retrying %d failed sinks.\n", len(hfd.failedSinks))
+	hfd.failedSinks = writeSinks(hfd.failedSinks, hfd.failedSnapshot)
+	hfd.scheduleSinkRetry()
+}
+
+// Must be called with the sinks lock held.
+func (hfd *HostsFileDaemon) scheduleSinkRetry() {
+	if len(hfd.failedSinks) == 0 {
+		return
+	}
+
+	if hfd.sinkRetryTimer == nil {
+		hfd.sinkRetryTimer = time.AfterFunc(hfd.sinkRetryInterval, hfd.retryFailedSinks)
+	} else {
+		hfd.sinkRetryTimer.Reset(hfd.sinkRetryInterval)
+	}
+}
+
+// Returns the sinks that failed to update.
+func writeSinks(sinks []DaemonSink, snapshot hostsfile.Snapshot) []DaemonSink {
+	failed := []DaemonSink{}
+	for _, sink := range sinks {
+		if err := sink.Update(snapshot); err != nil {
+			log.Printf("Failed to update %s: %s\n", sink.Name(), err.Error())
+			failed = append(failed, sink)
+		}
+	}
+
+	return failed
+}
+
 func (hfd *HostsFileDaemon) updateAfterInterval(delay time.Duration) {
 	time.Sleep(delay)
 	log.Println("Forcing update to ensure consistency")
 	hfd.updatesChannel <- true
 }
+
+// Turns a name given by a user into a fully qualified name in the search
+// domain, unless it's already in the search domain. Names may be given with
+// or without a trailing dot.
+func qualifyHostname(name, searchDomain string) string {
+	name = strings.TrimSuffix(name, ".")
+	searchDomain = strings.TrimSuffix(searchDomain, ".")
+	if name == searchDomain || strings.HasSuffix(name, "."+searchDomain) {
+		return name + "."
+	}
+
+	return fmt.Sprintf("%s.%s.", name, searchDomain)
+}
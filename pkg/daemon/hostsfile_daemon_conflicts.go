@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Objects with a higher priority keep hostnames claimed with different records
+// by other objects, when running with the priority conflict policy.
+const PriorityAnnotation string = "hostsfile-generator/priority"
+
+// Records what's needed to resolve and report conflicts involving the object.
+// Returns whether anything about the object changed.
+func (hfd *HostsFileDaemon) setObject(objectId string, obj interface{}) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	info := hostsfile.ObjectInfo{
+		CreationTimestamp: accessor.GetCreationTimestamp().Time,
+	}
+
+	if priority, ok := accessor.GetAnnotations()[PriorityAnnotation]; ok {
+		info.Priority, err = strconv.Atoi(priority)
+		if err != nil {
+			log.Printf("Ignoring invalid priority on %s: %s\n", objectId, priority)
+		}
+	}
+
+	if runtimeObject, ok := obj.(runtime.Object); ok {
+		hfd.objectsLock.Lock()
+		hfd.objects[objectId] = runtimeObject
+		hfd.objectsLock.Unlock()
+	}
+
+	return hfd.hostsfile.SetObjectInfo(objectId, info)
+}
+
+func (hfd *HostsFileDaemon) forgetObject(objectId string) {
+	hfd.objectsLock.Lock()
+	delete(hfd.objects, objectId)
+	hfd.objectsLock.Unlock()
+}
+
+// Logs conflicts, and records events against the objects that lost them.
+// Each conflict is only reported once, for as long as it stays the same.
+func (hfd *HostsFileDaemon) reportConflicts() {
+	current := map[string]bool{}
+	for _, conflict := range hfd.hostsfile.Conflicts() {
+		key := fmt.Sprintf("%s %s %s", conflict.Hostname, conflict.Winner, strings.Join(conflict.Losers, ","))
+		current[key] = true
+		if hfd.reportedConflicts[key] {
+			continue
+		}
+
+		var message string
+		if conflict.Winner == "" {
+			message = fmt.Sprintf("Hostname %s is claimed with different records by %s; dropping it from all of them", conflict.Hostname, strings.Join(conflict.Losers, ", "))
+		} else {
+			message = fmt.Sprintf("Hostname %s is claimed with different records by %s; keeping %s", conflict.Hostname, strings.Join(conflict.Losers, ", "), conflict.Winner)
+		}
+		log.Println(message)
+
+		for _, objectId := range conflict.Losers {
+			hfd.recordConflictEvent(objectId, message)
+		}
+	}
+
+	hfd.reportedConflicts = current
+}
+
+// Objects that don't come from Kubernetes, like static files, can only have
+// their conflicts logged.
+func (hfd *HostsFileDaemon) recordConflictEvent(objectId, message string) {
+	if hfd.recorder == nil {
+		return
+	}
+
+	hfd.objectsLock.Lock()
+	obj, ok := hfd.objects[objectId]
+	hfd.objectsLock.Unlock()
+
+	if ok {
+		hfd.recorder.Event(obj, v1.EventTypeWarning, "HostnameConflict", message)
+	}
+}
@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func TestReportConflicts(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+	recorder := record.NewFakeRecorder(10)
+	hfd.recorder = recorder
+
+	dsm := DaemonServiceMonitor{"internal.aleemhaji.com"}
+	f := hfd.InformerAddFunc(&dsm)
+
+	s1 := validTestService()
+	s1.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	f(s1)
+
+	s2 := validTestService()
+	s2.ObjectMeta.Namespace = "other"
+	s2.ObjectMeta.CreationTimestamp = metav1.Now()
+	s2.Spec.LoadBalancerIP = "192.168.1.3"
+	f(s2)
+
+	hfd.reportConflicts()
+	assert.Equal(t, 1, len(recorder.Events))
+	assert.Equal(t, "Warning HostnameConflict Hostname some-service.internal.aleemhaji.com is claimed with different records by v1.service/other/some-service; keeping v1.service/default/some-service", <-recorder.Events)
+	assert.Equal(t, "192.168.1.2\tsome-service.internal.aleemhaji.com.\n", hfd.hostsfile.String())
+
+	// Conflicts are only reported once.
+	hfd.reportConflicts()
+	assert.Equal(t, 0, len(recorder.Events))
+
+	// A higher priority doesn't matter with the default policy.
+	s2.Annotations = map[string]string{PriorityAnnotation: "10"}
+	hfd.InformerUpdateFunc(&dsm)(s2, s2)
+	hfd.reportConflicts()
+	assert.Equal(t, 0, len(recorder.Events))
+}
+
+func TestReportConflictsPriority(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	dc.ConflictPolicy = hostsfile.ConflictPriority
+	hfd := NewHostsFileDaemon(*dc)
+	recorder := record.NewFakeRecorder(10)
+	hfd.recorder = recorder
+
+	dsm := DaemonServiceMonitor{"internal.aleemhaji.com"}
+	f := hfd.InformerAddFunc(&dsm)
+
+	s1 := validTestService()
+	s1.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	f(s1)
+
+	s2 := validTestService()
+	s2.ObjectMeta.Namespace = "other"
+	s2.ObjectMeta.CreationTimestamp = metav1.Now()
+	s2.Annotations = map[string]string{PriorityAnnotation: "10"}
+	s2.Spec.LoadBalancerIP = "192.168.1.3"
+	f(s2)
+
+	hfd.reportConflicts()
+	assert.Equal(t, 1, len(recorder.Events))
+	assert.Equal(t, "Warning HostnameConflict Hostname some-service.internal.aleemhaji.com is claimed with different records by v1.service/default/some-service; keeping v1.service/other/some-service", <-recorder.Events)
+	assert.Equal(t, "192.168.1.3\tsome-service.internal.aleemhaji.com.\n", hfd.hostsfile.String())
+
+	// Once the conflict is gone, it can be reported again if it comes back.
+	hfd.InformerDeleteFunc(&dsm)(s2)
+	hfd.reportConflicts()
+	f(s2)
+	hfd.reportConflicts()
+	assert.Equal(t, 1, len(recorder.Events))
+}
@@ -1,13 +1,21 @@
 package daemon
 
 import (
+	"errors"
+	"sync"
 	"testing"
+	"time"
 )
 
 import (
 	"github.com/stretchr/testify/assert"
 
+	"k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
 )
 
 func TestInformerAddFunc(t *testing.T) {
@@ -38,6 +46,54 @@ func TestInformerAddFuncWrongType(t *testing.T) {
 	assert.Equal(t, 0, len(hfd.updatesChannel))
 }
 
+func TestInformerAddFuncInvalidEntries(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+	dsm := DaemonBetaIngressMonitor{"192.168.1.1", "internal.aleemhaji.com"}
+	f := hfd.InformerAddFunc(&dsm)
+
+	i := validTestBetaIngress()
+	i.Spec.Rules = []extensionsv1beta1.IngressRule{
+		extensionsv1beta1.IngressRule{
+			Host: "*.internal.aleemhaji.com",
+		},
+		extensionsv1beta1.IngressRule{
+			Host: "some_ingress.internal.aleemhaji.com",
+		},
+		extensionsv1beta1.IngressRule{
+			Host: "some-ingress.internal.aleemhaji.com",
+		},
+	}
+	f(i)
+
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+	assert.Equal(t, "192.168.1.1\tsome-ingress.internal.aleemhaji.com.\n", hfd.hostsfile.String())
+}
+
+func TestInformerAddFuncRecords(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+	dsm := DaemonServiceMonitor{"internal.aleemhaji.com"}
+	f := hfd.InformerAddFunc(&dsm)
+
+	s := validTestService()
+	s.Annotations = map[string]string{ServiceAliasesAnnotation: "other,bad_alias"}
+	f(s)
+
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+	assert.Equal(t, []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("other.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+	}, hfd.hostsfile.Snapshot().Groups[0].Records)
+
+	// Resyncs don't count as updates.
+	f(s)
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+}
+
 func TestInformerDeleteFunc(t *testing.T) {
 	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
 	assert.Nil(t, err)
@@ -49,8 +105,8 @@ func TestInformerDeleteFunc(t *testing.T) {
 
 	objectId, err := dsm.ValidateResource(i)
 	assert.NoError(t, err)
-	he := dsm.GetResourceHostsEntry(i)
-	hfd.hostsfile.SetHostsEntry(objectId, he)
+	he := dsm.GetResourceHostsEntries(i)
+	hfd.hostsfile.SetHostsEntries(objectId, he)
 
 	f := hfd.InformerDeleteFunc(&dsm)
 	f(i)
@@ -69,8 +125,8 @@ func TestInformerDeleteFuncWrongType(t *testing.T) {
 
 	objectId, err := dsm.ValidateResource(i)
 	assert.NoError(t, err)
-	he := dsm.GetResourceHostsEntry(i)
-	hfd.hostsfile.SetHostsEntry(objectId, he)
+	he := dsm.GetResourceHostsEntries(i)
+	hfd.hostsfile.SetHostsEntries(objectId, he)
 
 	ii := validTestService()
 	f := hfd.InformerDeleteFunc(&dsm)
@@ -79,6 +135,55 @@ func TestInformerDeleteFuncWrongType(t *testing.T) {
 	assert.Equal(t, 0, len(hfd.updatesChannel))
 }
 
+func TestInformerDeleteFuncTerminatingPod(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+	dsm := DaemonPodMonitor{"internal.aleemhaji.com"}
+
+	pod := validTestPod()
+	f := hfd.InformerAddFunc(&dsm)
+	f(pod)
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+	<-hfd.updatesChannel
+
+	// By the time it's deleted, it's been terminating and unready for a while.
+	terminated := *pod
+	now := metav1.Now()
+	terminated.ObjectMeta.DeletionTimestamp = &now
+	terminated.Status.Conditions = []v1.PodCondition{
+		v1.PodCondition{Type: v1.PodReady, Status: v1.ConditionFalse},
+	}
+
+	ff := hfd.InformerDeleteFunc(&dsm)
+	ff(&terminated)
+
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+	assert.False(t, hfd.hostsfile.RemoveHostsEntry("v1.pod/default/some-pod"))
+	assert.Equal(t, 0, len(hfd.objects))
+}
+
+func TestInformerDeleteFuncTombstone(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	hfd := NewHostsFileDaemon(*dc)
+	dsm := DaemonPodMonitor{"internal.aleemhaji.com"}
+
+	pod := validTestPod()
+	f := hfd.InformerAddFunc(&dsm)
+	f(pod)
+	<-hfd.updatesChannel
+
+	ff := hfd.InformerDeleteFunc(&dsm)
+	ff(cache.DeletedFinalStateUnknown{Key: "default/some-pod", Obj: pod})
+
+	assert.Equal(t, 1, len(hfd.updatesChannel))
+	assert.False(t, hfd.hostsfile.RemoveHostsEntry("v1.pod/default/some-pod"))
+	assert.Equal(t, 0, len(hfd.objects))
+}
+
 func TestInformerUpdateFunc(t *testing.T) {
 	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
 	assert.Nil(t, err)
@@ -90,8 +195,8 @@ func TestInformerUpdateFunc(t *testing.T) {
 
 	objectId, err := dsm.ValidateResource(i)
 	assert.NoError(t, err)
-	he := dsm.GetResourceHostsEntry(i)
-	hfd.hostsfile.SetHostsEntry(objectId, he)
+	he := dsm.GetResourceHostsEntries(i)
+	hfd.hostsfile.SetHostsEntries(objectId, he)
 
 	ii := *i
 	ii.Spec.Rules = []extensionsv1beta1.IngressRule{
@@ -117,8 +222,8 @@ func TestInformerUpdateFuncWrongType(t *testing.T) {
 
 	objectId, err := dsm.ValidateResource(i)
 	assert.NoError(t, err)
-	he := dsm.GetResourceHostsEntry(i)
-	hfd.hostsfile.SetHostsEntry(objectId, he)
+	he := dsm.GetResourceHostsEntries(i)
+	hfd.hostsfile.SetHostsEntries(objectId, he)
 
 	ii := validTestService()
 	f := hfd.InformerUpdateFunc(&dsm)
@@ -138,8 +243,8 @@ func TestInformerUpdateFuncInvalidated(t *testing.T) {
 
 	objectId, err := dsm.ValidateResource(i)
 	assert.NoError(t, err)
-	he := dsm.GetResourceHostsEntry(i)
-	hfd.hostsfile.SetHostsEntry(objectId, he)
+	he := dsm.GetResourceHostsEntries(i)
+	hfd.hostsfile.SetHostsEntries(objectId, he)
 
 	ii := *i
 	ii.Annotations["kubernetes.io/ingress.class"] = "nginx-external"
@@ -152,3 +257,98 @@ func TestInformerUpdateFuncInvalidated(t *testing.T) {
 	// Assert that the entry has been removed from the update.
 	assert.False(t, hfd.hostsfile.RemoveHostsEntry(objectId))
 }
+
+// Fails with err until it's cleared.
+type testSink struct {
+	lock    sync.Mutex
+	name    string
+	err     error
+	updates int
+}
+
+func (s *testSink) Name() string {
+	return s.name
+}
+
+func (s *testSink) Update(snapshot hostsfile.Snapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.updates++
+	return nil
+}
+
+func (s *testSink) Updates() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.updates
+}
+
+func (s *testSink) SetError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+}
+
+func TestUpdateSinksFailure(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	failing := &testSink{name: "failing", err: errors.New("connection refused")}
+	working := &testSink{name: "working"}
+	dc.DisablePihole = true
+	dc.Sinks = []DaemonSink{failing, working}
+
+	hfd := NewHostsFileDaemon(*dc)
+	hfd.sinkRetryInterval = time.Hour
+
+	hfd.updateSinks(hostsfile.Snapshot{})
+	assert.Equal(t, 0, failing.Updates())
+	assert.Equal(t, 1, working.Updates())
+	assert.Equal(t, []DaemonSink{failing}, hfd.failedSinks)
+
+	// Only the failed sink is retried.
+	failing.SetError(nil)
+	hfd.retryFailedSinks()
+	assert.Equal(t, 1, failing.Updates())
+	assert.Equal(t, 1, working.Updates())
+	assert.Equal(t, []DaemonSink{}, hfd.failedSinks)
+
+	hfd.retryFailedSinks()
+	assert.Equal(t, 1, failing.Updates())
+}
+
+func TestUpdateSinksRetry(t *testing.T) {
+	dc, err := NewDaemonConfig("1", "2", "3", "4", "5")
+	assert.Nil(t, err)
+
+	failing := &testSink{name: "failing", err: errors.New("connection refused")}
+	dc.DisablePihole = true
+	dc.Sinks = []DaemonSink{failing}
+
+	hfd := NewHostsFileDaemon(*dc)
+	hfd.sinkRetryInterval = time.Millisecond * 10
+
+	hfd.updateSinks(hostsfile.Snapshot{})
+	failing.SetError(nil)
+	assert.Eventually(t, func() bool { return failing.Updates() == 1 }, time.Second, time.Millisecond*10)
+}
+
+func TestQualifyHostname(t *testing.T) {
+	assert.Equal(t, "foo.internal.aleemhaji.com.", qualifyHostname("foo", "internal.aleemhaji.com"))
+	assert.Equal(t, "foo.internal.aleemhaji.com.", qualifyHostname("foo.internal.aleemhaji.com", "internal.aleemhaji.com"))
+	assert.Equal(t, "internal.aleemhaji.com.", qualifyHostname("internal.aleemhaji.com", "internal.aleemhaji.com"))
+
+	// Already fully qualified.
+	assert.Equal(t, "foo.internal.aleemhaji.com.", qualifyHostname("foo.internal.aleemhaji.com.", "internal.aleemhaji.com"))
+	assert.Equal(t, "foo.internal.aleemhaji.com.", qualifyHostname("foo.internal.aleemhaji.com.", "internal.aleemhaji.com."))
+
+	// Only whole labels count as being in the search domain.
+	assert.Equal(t, "myinternal.aleemhaji.com.internal.aleemhaji.com.", qualifyHostname("myinternal.aleemhaji.com", "internal.aleemhaji.com"))
+}
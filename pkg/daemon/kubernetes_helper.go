@@ -4,11 +4,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
@@ -70,3 +80,21 @@ func ExecInPod(config *rest.Config, clientset *kubernetes.Clientset, podName str
 		Stderr: os.Stderr,
 	})
 }
+
+// Custom resources aren't known to the typed informer factory, so register a
+// dynamic informer with it instead. That way the informer is started and
+// synced alongside the factory's other informers.
+// Each monitor has its own factory, so keying on the unstructured type is
+// safe.
+func DynamicResourceInformer(sif informers.SharedInformerFactory, client dynamic.Interface, gvr schema.GroupVersionResource) cache.SharedInformer {
+	return sif.InformerFor(&unstructured.Unstructured{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+		return dynamicinformer.NewFilteredDynamicInformer(client, gvr, metav1.NamespaceAll, resync, indexers, nil).Informer()
+	})
+}
+
+func NewEventRecorder(clientset *kubernetes.Clientset) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "hostsfile-generator"})
+}
@@ -0,0 +1,657 @@
+package dnsserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/miekg/dns"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+// Zone is the domain the server is authoritative for; names outside of it are
+// forwarded to Upstream, or refused if there isn't one.
+// Nameservers and Hostmaster only fill in the zone's SOA and NS records, and
+// default to names in the zone itself.
+// Secondaries are sent a NOTIFY whenever the zone changes, and can transfer
+// it, along with any address in the AllowTransfer networks. History is how
+// many changes are kept for IXFR.
+type ServerOptions struct {
+	Zone        string
+	Nameservers []string
+	Hostmaster  string
+	Ttl         uint32
+	Upstream    string
+
+	Secondaries   []string
+	AllowTransfer []string
+	History       int
+}
+
+// Answers queries for a single zone from the records it was last updated with.
+// Until the first update, queries in the zone fail rather than getting
+// NXDOMAIN, so resolvers don't cache answers from an empty zone.
+type Server struct {
+	options ServerOptions
+
+	lock    *sync.RWMutex
+	ready   bool
+	serial  uint32
+	records []dns.RR
+	history []change
+
+	// Every name in the zone, including names that only exist because
+	// something below them does, along with the records they hold.
+	names map[string][]dns.RR
+
+	allowTransfer []*net.IPNet
+	servers       []*dns.Server
+}
+
+// The records removed and added when the serial went from one to the other.
+type change struct {
+	from    uint32
+	to      uint32
+	removed []dns.RR
+	added   []dns.RR
+}
+
+func NewServer(options ServerOptions) (*Server, error) {
+	options.Zone = dns.Fqdn(strings.ToLower(options.Zone))
+	if options.Zone == "." {
+		return nil, errors.New("dns server requires a zone")
+	}
+
+	if len(options.Nameservers) == 0 {
+		options.Nameservers = []string{"ns." + options.Zone}
+	}
+
+	if options.Hostmaster == "" {
+		options.Hostmaster = "hostmaster." + options.Zone
+	}
+	options.Hostmaster = strings.Replace(options.Hostmaster, "@", ".", 1)
+
+	if options.Ttl == 0 {
+		options.Ttl = 60
+	}
+
+	if options.History == 0 {
+		options.History = 10
+	}
+
+	allowTransfer := []*net.IPNet{}
+	options.Secondaries = append([]string{}, options.Secondaries...)
+	for i, secondary := range options.Secondaries {
+		if _, _, err := net.SplitHostPort(secondary); err != nil {
+			secondary = net.JoinHostPort(secondary, "53")
+			options.Secondaries[i] = secondary
+		}
+
+		host, _, _ := net.SplitHostPort(secondary)
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("secondary %s must be an IP address", secondary)
+		}
+		allowTransfer = append(allowTransfer, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+
+	for _, network := range options.AllowTransfer {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, err
+		}
+		allowTransfer = append(allowTransfer, ipNet)
+	}
+
+	s := Server{
+		options:       options,
+		lock:          &sync.RWMutex{},
+		names:         map[string][]dns.RR{},
+		allowTransfer: allowTransfer,
+	}
+	return &s, nil
+}
+
+func (s *Server) Name() string {
+	return "dns server"
+}
+
+// Replaces the zone's records. The serial only changes if they did, and
+// secondaries are only notified when it does.
+func (s *Server) Update(snapshot hostsfile.Snapshot) error {
+	records := s.snapshotRecords(snapshot)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ready && recordsEqual(s.records, records) {
+		return nil
+	}
+
+	serial := nextSerial(s.serial)
+	if s.ready {
+		removed, added := DiffRecords(s.records, records)
+		s.history = append(s.history, change{s.serial, serial, removed, added})
+		if len(s.history) > s.options.History {
+			s.history = s.history[len(s.history)-s.options.History:]
+		}
+	}
+	s.serial = serial
+
+	s.ready = true
+	s.records = records
+	s.names = map[string][]dns.RR{}
+	for _, rr := range append(s.apexRecords(), records...) {
+		name := rr.Header().Name
+		s.names[name] = append(s.names[name], rr)
+		for parent := parentName(name); parent != "" && dns.IsSubDomain(s.options.Zone, parent); parent = parentName(parent) {
+			if _, ok := s.names[parent]; !ok {
+				s.names[parent] = []dns.RR{}
+			}
+		}
+	}
+
+	soa := s.soa()
+	for _, secondary := range s.options.Secondaries {
+		go s.notify(secondary, soa)
+	}
+
+	return nil
+}
+
+func (s *Server) Serial() uint32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.serial
+}
+
+// Binds both UDP and TCP, and waits for them to start serving before
+// returning, so that a port that's in use is reported straight away.
+// The TCP listener uses the port the UDP socket got, so that port 0 can be
+// used for both.
+func (s *Server) Listen(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+
+	started := sync.WaitGroup{}
+	started.Add(2)
+
+	udp := &dns.Server{PacketConn: pc, Handler: s, NotifyStartedFunc: started.Done}
+	tcp := &dns.Server{Listener: l, Handler: s, NotifyStartedFunc: started.Done}
+	s.servers = []*dns.Server{udp, tcp}
+	for _, server := range s.servers {
+		go func(server *dns.Server) {
+			if err := server.ActivateAndServe(); err != nil {
+				log.Printf("DNS server stopped: %s\n", err.Error())
+			}
+		}(server)
+	}
+
+	started.Wait()
+	return nil
+}
+
+func (s *Server) Addr() string {
+	if len(s.servers) == 0 {
+		return ""
+	}
+
+	return s.servers[0].PacketConn.LocalAddr().String()
+}
+
+func (s *Server) Shutdown() error {
+	for _, server := range s.servers {
+		if err := server.Shutdown(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) != 1 {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeFormatError)
+		w.WriteMsg(m)
+		return
+	}
+
+	if req.Opcode != dns.OpcodeQuery {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeNotImplemented)
+		w.WriteMsg(m)
+		return
+	}
+
+	if !dns.IsSubDomain(s.options.Zone, strings.ToLower(req.Question[0].Name)) {
+		s.forward(w, req)
+		return
+	}
+
+	if qtype := req.Question[0].Qtype; qtype == dns.TypeAXFR || qtype == dns.TypeIXFR {
+		s.transfer(w, req)
+		return
+	}
+
+	m := s.answer(req)
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		m.Truncate(size)
+	}
+	w.WriteMsg(m)
+}
+
+// CNAMEs are followed as long as they stay in the zone; anything else is left
+// to the resolver.
+func (s *Server) answer(req *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if !s.ready {
+		m.Rcode = dns.RcodeServerFailure
+		return m
+	}
+
+	question := req.Question[0]
+	name := strings.ToLower(question.Name)
+	rrs, ok := s.lookup(name)
+	if !ok {
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{s.soa()}
+		return m
+	}
+
+	for i := 0; i < 8; i++ {
+		matched := []dns.RR{}
+		var cname *dns.CNAME
+		for _, rr := range rrs {
+			if question.Qtype == dns.TypeANY || rr.Header().Rrtype == question.Qtype {
+				matched = append(matched, rr)
+			} else if c, ok := rr.(*dns.CNAME); ok {
+				cname = c
+			}
+		}
+
+		if len(matched) != 0 || cname == nil {
+			m.Answer = append(m.Answer, matched...)
+			break
+		}
+
+		m.Answer = append(m.Answer, cname)
+		if !dns.IsSubDomain(s.options.Zone, cname.Target) {
+			break
+		}
+
+		if rrs, ok = s.lookup(cname.Target); !ok {
+			break
+		}
+	}
+
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{s.soa()}
+	}
+
+	return m
+}
+
+// Wildcards only answer for names below the closest name that exists, as
+// RFC 4592 describes; owner names are rewritten to the name asked for.
+func (s *Server) lookup(name string) ([]dns.RR, bool) {
+	if rrs, ok := s.names[name]; ok {
+		return rrs, true
+	}
+
+	for parent := parentName(name); parent != "" && dns.IsSubDomain(s.options.Zone, parent); parent = parentName(parent) {
+		if _, ok := s.names[parent]; !ok {
+			continue
+		}
+
+		wildcard, ok := s.names["*."+parent]
+		if !ok {
+			return nil, false
+		}
+
+		rrs := []dns.RR{}
+		for _, rr := range wildcard {
+			synthesized := dns.Copy(rr)
+			synthesized.Header().Name = name
+			rrs = append(rrs, synthesized)
+		}
+		return rrs, true
+	}
+
+	return nil, false
+}
+
+func (s *Server) forward(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	if s.options.Upstream == "" {
+		m.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	client := dns.Client{Net: "udp", Timeout: 5 * time.Second}
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		client.Net = "tcp"
+	}
+
+	resp, _, err := client.Exchange(req, s.options.Upstream)
+	if err != nil {
+		log.Printf("Failed to forward query for %s to %s: %s\n", req.Question[0].Name, s.options.Upstream, err.Error())
+		m.SetRcode(req, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+
+	w.WriteMsg(resp)
+}
+
+// Transfers are only answered over TCP, for the zone itself, to addresses
+// allowed to make them.
+// IXFR requests are answered with the changes since the serial the secondary
+// has, or the whole zone if those changes aren't kept anymore. Over UDP, they
+// only get the current SOA, which tells the secondary to retry over TCP if it
+// isn't up to date.
+func (s *Server) transfer(w dns.ResponseWriter, req *dns.Msg) {
+	question := req.Question[0]
+	_, tcp := w.RemoteAddr().(*net.TCPAddr)
+	if !s.transferAllowed(w.RemoteAddr()) || dns.Fqdn(strings.ToLower(question.Name)) != s.options.Zone || (!tcp && question.Qtype == dns.TypeAXFR) {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	s.lock.RLock()
+	if !s.ready {
+		s.lock.RUnlock()
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+
+	rrs := []dns.RR{}
+	if question.Qtype == dns.TypeIXFR && !tcp {
+		rrs = []dns.RR{s.soa()}
+	} else if question.Qtype == dns.TypeIXFR {
+		rrs = s.ixfrRecords(req)
+	}
+
+	if len(rrs) == 0 {
+		rrs = append(s.apexRecords(), s.records...)
+		rrs = append(rrs, s.soa())
+	}
+	s.lock.RUnlock()
+
+	for i := 0; i < len(rrs); i += 100 {
+		end := i + 100
+		if end > len(rrs) {
+			end = len(rrs)
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		m.Answer = rrs[i:end]
+		if err := w.WriteMsg(m); err != nil {
+			log.Printf("Failed to transfer %s to %s: %s\n", s.options.Zone, w.RemoteAddr().String(), err.Error())
+			return
+		}
+	}
+}
+
+// Returns nothing if the changes since the secondary's serial aren't kept, so
+// that the whole zone gets sent instead.
+func (s *Server) ixfrRecords(req *dns.Msg) []dns.RR {
+	if len(req.Ns) != 1 {
+		return nil
+	}
+
+	soa, ok := req.Ns[0].(*dns.SOA)
+	if !ok {
+		return nil
+	}
+
+	if soa.Serial == s.serial {
+		return []dns.RR{s.soa()}
+	}
+
+	for i, c := range s.history {
+		if c.from != soa.Serial {
+			continue
+		}
+
+		rrs := []dns.RR{s.soa()}
+		for _, c := range s.history[i:] {
+			rrs = append(rrs, s.soaAt(c.from))
+			rrs = append(rrs, c.removed...)
+			rrs = append(rrs, s.soaAt(c.to))
+			rrs = append(rrs, c.added...)
+		}
+		return append(rrs, s.soa())
+	}
+
+	return nil
+}
+
+func (s *Server) transferAllowed(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+
+	for _, network := range s.allowTransfer {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Failures are only logged; the secondary will still pick up the change when
+// its refresh timer runs out.
+func (s *Server) notify(secondary string, soa dns.RR) {
+	m := new(dns.Msg)
+	m.SetNotify(s.options.Zone)
+	m.Answer = []dns.RR{soa}
+
+	client := dns.Client{Net: "udp", Timeout: 5 * time.Second}
+	var err error
+	for i := 0; i < 3; i++ {
+		var resp *dns.Msg
+		resp, _, err = client.Exchange(m, secondary)
+		if err == nil && resp.Rcode != dns.RcodeSuccess {
+			err = fmt.Errorf("got %s", dns.RcodeToString[resp.Rcode])
+		}
+		if err == nil {
+			return
+		}
+	}
+
+	log.Printf("Failed to notify %s of %s serial %d: %s\n", secondary, s.options.Zone, soa.(*dns.SOA).Serial, err.Error())
+}
+
+func (s *Server) soa() dns.RR {
+	return s.soaAt(s.serial)
+}
+
+func (s *Server) soaAt(serial uint32) dns.RR {
+	return &dns.SOA{
+		Hdr:     s.header(s.options.Zone, dns.TypeSOA),
+		Ns:      dns.Fqdn(s.options.Nameservers[0]),
+		Mbox:    dns.Fqdn(s.options.Hostmaster),
+		Serial:  serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  604800,
+		Minttl:  s.options.Ttl,
+	}
+}
+
+func (s *Server) apexRecords() []dns.RR {
+	rrs := []dns.RR{s.soa()}
+	for _, nameserver := range s.options.Nameservers {
+		rrs = append(rrs, &dns.NS{Hdr: s.header(s.options.Zone, dns.TypeNS), Ns: dns.Fqdn(nameserver)})
+	}
+
+	return rrs
+}
+
+func (s *Server) snapshotRecords(snapshot hostsfile.Snapshot) []dns.RR {
+	return ZoneRecords(s.options.Zone, s.options.Ttl, snapshot)
+}
+
+// The snapshot's records as DNS records, leaving out names outside of the
+// zone.
+// Records are deduplicated and sorted, so the same snapshot always gives the
+// same records.
+func ZoneRecords(zone string, ttl uint32, snapshot hostsfile.Snapshot) []dns.RR {
+	zone = dns.Fqdn(strings.ToLower(zone))
+
+	rrs := []dns.RR{}
+	seen := map[string]bool{}
+	add := func(rr dns.RR) {
+		if !dns.IsSubDomain(zone, rr.Header().Name) || seen[rr.String()] {
+			return
+		}
+
+		seen[rr.String()] = true
+		rrs = append(rrs, rr)
+	}
+
+	for _, group := range snapshot.Groups {
+		for _, entry := range group.Entries {
+			ip := net.ParseIP(strings.SplitN(entry.Ip(), "%", 2)[0])
+			if ip == nil {
+				continue
+			}
+
+			for _, host := range entry.Hosts() {
+				name := dns.Fqdn(strings.ToLower(host))
+				if v4 := ip.To4(); v4 != nil {
+					add(&dns.A{Hdr: header(name, dns.TypeA, ttl), A: v4})
+				} else {
+					add(&dns.AAAA{Hdr: header(name, dns.TypeAAAA, ttl), AAAA: ip})
+				}
+			}
+		}
+
+		for _, record := range group.Records {
+			name := dns.Fqdn(strings.ToLower(record.Name()))
+			switch record.Type() {
+			case hostsfile.RecordCNAME:
+				add(&dns.CNAME{Hdr: header(name, dns.TypeCNAME, ttl), Target: dns.Fqdn(strings.ToLower(record.Target()))})
+			case hostsfile.RecordSRV:
+				add(&dns.SRV{Hdr: header(name, dns.TypeSRV, ttl), Priority: record.Priority(), Weight: record.Weight(), Port: record.Port(), Target: dns.Fqdn(strings.ToLower(record.Target()))})
+			case hostsfile.RecordTXT:
+				add(&dns.TXT{Hdr: header(name, dns.TypeTXT, ttl), Txt: record.Text()})
+			}
+		}
+	}
+
+	sort.Slice(rrs, func(i, j int) bool {
+		return rrs[i].String() < rrs[j].String()
+	})
+
+	return rrs
+}
+
+func (s *Server) header(name string, rrtype uint16) dns.RR_Header {
+	return header(name, rrtype, s.options.Ttl)
+}
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+// Same scheme as zone file serials: never decreasing, and at least the
+// current Unix time.
+func nextSerial(serial uint32) uint32 {
+	next := serial + 1
+	if now := uint32(time.Now().Unix()); now > next {
+		next = now
+	}
+
+	return next
+}
+
+func parentName(name string) string {
+	i, end := dns.NextLabel(name, 0)
+	if end {
+		return ""
+	}
+
+	return name[i:]
+}
+
+// The records only in a, and the records only in b.
+func DiffRecords(a, b []dns.RR) ([]dns.RR, []dns.RR) {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, rr := range a {
+		inA[rr.String()] = true
+	}
+	for _, rr := range b {
+		inB[rr.String()] = true
+	}
+
+	removed, added := []dns.RR{}, []dns.RR{}
+	for _, rr := range a {
+		if !inB[rr.String()] {
+			removed = append(removed, rr)
+		}
+	}
+	for _, rr := range b {
+		if !inA[rr.String()] {
+			added = append(added, rr)
+		}
+	}
+
+	return removed, added
+}
+
+func recordsEqual(a, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+
+	return true
+}
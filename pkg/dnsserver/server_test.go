@@ -0,0 +1,336 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+)
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/hostsfile-generator/pkg/hostsfile"
+)
+
+func testSnapshot() hostsfile.Snapshot {
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.2", []string{"some-service.internal.aleemhaji.com.", "google.com"}),
+		*hostsfile.NewHostsEntry("fd00::2", []string{"Some-Service.internal.aleemhaji.com"}),
+		*hostsfile.NewHostsEntry("192.168.1.3", []string{"*.apps.internal.aleemhaji.com", "exact.apps.internal.aleemhaji.com"}),
+	})
+	hf.SetRecords("abc", []hostsfile.Record{
+		*hostsfile.NewCNAMERecord("www.internal.aleemhaji.com.", "some-service.internal.aleemhaji.com."),
+		*hostsfile.NewCNAMERecord("external.internal.aleemhaji.com.", "google.com."),
+		*hostsfile.NewTXTRecord("some-service.internal.aleemhaji.com.", []string{"v=spf1 -all"}),
+	})
+
+	return hf.Snapshot()
+}
+
+func testServer(t *testing.T, options ServerOptions) *Server {
+	s, err := NewServer(options)
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Listen("127.0.0.1:0"))
+	t.Cleanup(func() {
+		s.Shutdown()
+	})
+
+	return s
+}
+
+func query(t *testing.T, s *Server, network, name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+
+	client := dns.Client{Net: network}
+	resp, _, err := client.Exchange(m, s.Addr())
+	assert.Nil(t, err)
+
+	return resp
+}
+
+func answers(m *dns.Msg) []string {
+	rv := []string{}
+	for _, rr := range m.Answer {
+		rv = append(rv, rr.String())
+	}
+
+	return rv
+}
+
+func TestNewServer(t *testing.T) {
+	_, err := NewServer(ServerOptions{})
+	assert.Equal(t, "dns server requires a zone", err.Error())
+
+	s, err := NewServer(ServerOptions{Zone: "Internal.aleemhaji.com", Hostmaster: "admin@aleemhaji.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "internal.aleemhaji.com.", s.options.Zone)
+	assert.Equal(t, []string{"ns.internal.aleemhaji.com."}, s.options.Nameservers)
+	assert.Equal(t, "admin.aleemhaji.com", s.options.Hostmaster)
+	assert.Equal(t, uint32(60), s.options.Ttl)
+}
+
+func TestServerNotReady(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com"})
+
+	resp := query(t, s, "udp", "some-service.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeServerFailure, resp.Rcode)
+}
+
+func TestServerAnswers(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	for _, network := range []string{"udp", "tcp"} {
+		resp := query(t, s, network, "some-service.internal.aleemhaji.com.", dns.TypeA)
+		assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		assert.True(t, resp.Authoritative)
+		assert.Equal(t, []string{"some-service.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.2"}, answers(resp))
+	}
+
+	resp := query(t, s, "udp", "SOME-SERVICE.internal.aleemhaji.com.", dns.TypeAAAA)
+	assert.Equal(t, []string{"some-service.internal.aleemhaji.com.\t30\tIN\tAAAA\tfd00::2"}, answers(resp))
+
+	resp = query(t, s, "udp", "some-service.internal.aleemhaji.com.", dns.TypeTXT)
+	assert.Equal(t, []string{"some-service.internal.aleemhaji.com.\t30\tIN\tTXT\t\"v=spf1 -all\""}, answers(resp))
+
+	resp = query(t, s, "udp", "internal.aleemhaji.com.", dns.TypeSOA)
+	assert.Equal(t, 1, len(resp.Answer))
+	assert.Equal(t, s.Serial(), resp.Answer[0].(*dns.SOA).Serial)
+}
+
+func TestServerCNAME(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	resp := query(t, s, "udp", "www.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, []string{
+		"www.internal.aleemhaji.com.\t30\tIN\tCNAME\tsome-service.internal.aleemhaji.com.",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.2",
+	}, answers(resp))
+
+	resp = query(t, s, "udp", "www.internal.aleemhaji.com.", dns.TypeCNAME)
+	assert.Equal(t, []string{"www.internal.aleemhaji.com.\t30\tIN\tCNAME\tsome-service.internal.aleemhaji.com."}, answers(resp))
+
+	// Targets outside of the zone are left to the resolver.
+	resp = query(t, s, "udp", "external.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, []string{"external.internal.aleemhaji.com.\t30\tIN\tCNAME\tgoogle.com."}, answers(resp))
+}
+
+func TestServerNegativeAnswers(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com"})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	resp := query(t, s, "udp", "missing.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	assert.Equal(t, 0, len(resp.Answer))
+	assert.Equal(t, dns.TypeSOA, resp.Ns[0].Header().Rrtype)
+
+	resp = query(t, s, "udp", "some-service.internal.aleemhaji.com.", dns.TypeMX)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Equal(t, 0, len(resp.Answer))
+	assert.Equal(t, dns.TypeSOA, resp.Ns[0].Header().Rrtype)
+
+	// Names that only exist because of names below them have no data, but
+	// still exist.
+	resp = query(t, s, "udp", "apps.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Equal(t, 0, len(resp.Answer))
+}
+
+func TestServerWildcards(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	resp := query(t, s, "udp", "anything.apps.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, []string{"anything.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3"}, answers(resp))
+
+	resp = query(t, s, "udp", "deeper.anything.apps.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, []string{"deeper.anything.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3"}, answers(resp))
+
+	// Names that exist aren't covered by the wildcard.
+	resp = query(t, s, "udp", "below.exact.apps.internal.aleemhaji.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+}
+
+func TestServerSerial(t *testing.T) {
+	s, err := NewServer(ServerOptions{Zone: "internal.aleemhaji.com"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Update(testSnapshot()))
+	serial := s.Serial()
+	assert.NotEqual(t, uint32(0), serial)
+
+	assert.Nil(t, s.Update(testSnapshot()))
+	assert.Equal(t, serial, s.Serial())
+
+	assert.Nil(t, s.Update(hostsfile.Snapshot{}))
+	assert.True(t, s.Serial() > serial)
+}
+
+func TestServerForwarding(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com"})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	resp := query(t, s, "udp", "google.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+
+	upstream := dns.NewServeMux()
+	upstream.HandleFunc("google.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		rr, _ := dns.NewRR("google.com. 300 IN A 142.250.72.14")
+		m.Answer = []dns.RR{rr}
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	assert.Nil(t, err)
+
+	for _, us := range []*dns.Server{&dns.Server{PacketConn: pc, Handler: upstream}, &dns.Server{Listener: l, Handler: upstream}} {
+		go us.ActivateAndServe()
+		defer us.Shutdown()
+	}
+
+	s = testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Upstream: pc.LocalAddr().String()})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	for _, network := range []string{"udp", "tcp"} {
+		resp = query(t, s, network, "google.com.", dns.TypeA)
+		assert.Equal(t, []string{"google.com.\t300\tIN\tA\t142.250.72.14"}, answers(resp))
+	}
+}
+
+func transfer(t *testing.T, s *Server, m *dns.Msg) []string {
+	tr := dns.Transfer{}
+	envelopes, err := tr.In(m, s.Addr())
+	assert.Nil(t, err)
+
+	rv := []string{}
+	for envelope := range envelopes {
+		assert.Nil(t, envelope.Error)
+		for _, rr := range envelope.RR {
+			rv = append(rv, rr.String())
+		}
+	}
+
+	return rv
+}
+
+func TestServerAXFR(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	m := new(dns.Msg)
+	m.SetAxfr("internal.aleemhaji.com.")
+	resp := query(t, s, "tcp", "internal.aleemhaji.com.", dns.TypeAXFR)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+
+	s = testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30, AllowTransfer: []string{"127.0.0.0/8"}})
+	assert.Nil(t, s.Update(testSnapshot()))
+
+	soa := s.soa().String()
+	assert.Equal(t, []string{
+		soa,
+		"internal.aleemhaji.com.\t30\tIN\tNS\tns.internal.aleemhaji.com.",
+		"*.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3",
+		"exact.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3",
+		"external.internal.aleemhaji.com.\t30\tIN\tCNAME\tgoogle.com.",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.2",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tAAAA\tfd00::2",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tTXT\t\"v=spf1 -all\"",
+		"www.internal.aleemhaji.com.\t30\tIN\tCNAME\tsome-service.internal.aleemhaji.com.",
+		soa,
+	}, transfer(t, s, m))
+
+	// Only over TCP.
+	resp = query(t, s, "udp", "internal.aleemhaji.com.", dns.TypeAXFR)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+}
+
+func TestServerIXFR(t *testing.T) {
+	s := testServer(t, ServerOptions{Zone: "internal.aleemhaji.com", Ttl: 30, AllowTransfer: []string{"127.0.0.1/32"}, History: 1})
+	assert.Nil(t, s.Update(testSnapshot()))
+	first := s.Serial()
+
+	hf := hostsfile.NewHostsFile()
+	hf.SetHostsEntries("abc", []hostsfile.HostsEntry{
+		*hostsfile.NewHostsEntry("192.168.1.4", []string{"some-service.internal.aleemhaji.com"}),
+	})
+	assert.Nil(t, s.Update(hf.Snapshot()))
+	second := s.Serial()
+
+	m := new(dns.Msg)
+	m.SetIxfr("internal.aleemhaji.com.", first, "ns.internal.aleemhaji.com.", "hostmaster.internal.aleemhaji.com.")
+	assert.Equal(t, []string{
+		s.soa().String(),
+		s.soaAt(first).String(),
+		"*.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3",
+		"exact.apps.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.3",
+		"external.internal.aleemhaji.com.\t30\tIN\tCNAME\tgoogle.com.",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.2",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tAAAA\tfd00::2",
+		"some-service.internal.aleemhaji.com.\t30\tIN\tTXT\t\"v=spf1 -all\"",
+		"www.internal.aleemhaji.com.\t30\tIN\tCNAME\tsome-service.internal.aleemhaji.com.",
+		s.soaAt(second).String(),
+		"some-service.internal.aleemhaji.com.\t30\tIN\tA\t192.168.1.4",
+		s.soa().String(),
+	}, transfer(t, s, m))
+
+	// Up to date.
+	m.SetIxfr("internal.aleemhaji.com.", second, "ns.internal.aleemhaji.com.", "hostmaster.internal.aleemhaji.com.")
+	assert.Equal(t, []string{s.soa().String()}, transfer(t, s, m))
+
+	// Changes that are no longer kept get the whole zone.
+	assert.Nil(t, s.Update(hostsfile.Snapshot{}))
+	m.SetIxfr("internal.aleemhaji.com.", first, "ns.internal.aleemhaji.com.", "hostmaster.internal.aleemhaji.com.")
+	assert.Equal(t, []string{
+		s.soa().String(),
+		"internal.aleemhaji.com.\t30\tIN\tNS\tns.internal.aleemhaji.com.",
+		s.soa().String(),
+	}, transfer(t, s, m))
+
+	// Over UDP, only the SOA.
+	m.SetIxfr("internal.aleemhaji.com.", first, "ns.internal.aleemhaji.com.", "hostmaster.internal.aleemhaji.com.")
+	resp, _, err := (&dns.Client{Net: "udp"}).Exchange(m, s.Addr())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{s.soa().String()}, answers(resp))
+}
+
+func TestServerNotify(t *testing.T) {
+	notifies := make(chan *dns.Msg, 10)
+	secondary := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		notifies <- req
+		m := new(dns.Msg)
+		m.SetReply(req)
+		w.WriteMsg(m)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	ss := dns.Server{PacketConn: pc, Handler: secondary}
+	go ss.ActivateAndServe()
+	defer ss.Shutdown()
+
+	s, err := NewServer(ServerOptions{Zone: "internal.aleemhaji.com", Secondaries: []string{pc.LocalAddr().String()}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Update(testSnapshot()))
+	req := <-notifies
+	assert.Equal(t, dns.OpcodeNotify, req.Opcode)
+	assert.Equal(t, "internal.aleemhaji.com.", req.Question[0].Name)
+	assert.Equal(t, s.Serial(), req.Answer[0].(*dns.SOA).Serial)
+
+	// Nothing changed, so nothing to notify about.
+	assert.Nil(t, s.Update(testSnapshot()))
+	assert.Nil(t, s.Update(hostsfile.Snapshot{}))
+	req = <-notifies
+	assert.Equal(t, s.Serial(), req.Answer[0].(*dns.SOA).Serial)
+	assert.Equal(t, 0, len(notifies))
+}
@@ -30,6 +30,20 @@ func (chfptr *ConcurrentHostsFile) SetHostsEntry(objectId string, he HostsEntry)
 	return rv
 }
 
+func (chfptr *ConcurrentHostsFile) SetHostsEntries(objectId string, entries []HostsEntry) bool {
+	chfptr.Lock()
+	rv := chfptr.hf.SetHostsEntries(objectId, entries)
+	chfptr.Unlock()
+	return rv
+}
+
+func (chfptr *ConcurrentHostsFile) SetRecords(objectId string, records []Record) bool {
+	chfptr.Lock()
+	rv := chfptr.hf.SetRecords(objectId, records)
+	chfptr.Unlock()
+	return rv
+}
+
 func (chfptr *ConcurrentHostsFile) RemoveHostsEntry(objectId string) bool {
 	chfptr.Lock()
 	rv := chfptr.hf.RemoveHostsEntry(objectId)
@@ -37,6 +51,33 @@ func (chfptr *ConcurrentHostsFile) RemoveHostsEntry(objectId string) bool {
 	return rv
 }
 
+func (chfptr *ConcurrentHostsFile) SetObjectInfo(objectId string, info ObjectInfo) bool {
+	chfptr.Lock()
+	rv := chfptr.hf.SetObjectInfo(objectId, info)
+	chfptr.Unlock()
+	return rv
+}
+
+func (chfptr *ConcurrentHostsFile) SetConflictPolicy(policy ConflictPolicy) {
+	chfptr.Lock()
+	chfptr.hf.SetConflictPolicy(policy)
+	chfptr.Unlock()
+}
+
+func (chfptr *ConcurrentHostsFile) Conflicts() []Conflict {
+	chfptr.lock.RLock()
+	rv := chfptr.hf.Conflicts()
+	chfptr.lock.RUnlock()
+	return rv
+}
+
+func (chfptr *ConcurrentHostsFile) Snapshot() Snapshot {
+	chfptr.lock.RLock()
+	rv := chfptr.hf.Snapshot()
+	chfptr.lock.RUnlock()
+	return rv
+}
+
 func (chfptr *ConcurrentHostsFile) String() string {
 	chfptr.lock.RLock()
 	rv := chfptr.hf.String()
@@ -24,6 +24,20 @@ func TestConcurrentHostsFileSetHostnames(t *testing.T) {
 	assert.True(t, hf.SetHostsEntry("abc", he2))
 }
 
+func TestConcurrentHostsFileSetHostsEntries(t *testing.T) {
+	hf := NewConcurrentHostsFile()
+
+	he1 := HostsEntry{"192.168.1.2", []string{"google.com"}}
+	he2 := HostsEntry{"192.168.1.3", []string{"google.com"}}
+
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he1, he2}))
+	assert.False(t, hf.SetHostsEntries("abc", []HostsEntry{he1, he2}))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he2, he1}))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he1}))
+	assert.False(t, hf.SetHostsEntry("abc", he1))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{}))
+}
+
 func TestConcurrentHostsFileRemoveHostnames(t *testing.T) {
 	hf := NewConcurrentHostsFile()
 
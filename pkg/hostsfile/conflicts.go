@@ -0,0 +1,151 @@
+package hostsfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How to pick which object keeps a hostname that several objects publish with
+// different IPs.
+type ConflictPolicy int
+
+const (
+	ConflictOldest ConflictPolicy = iota
+	ConflictPriority
+	ConflictDrop
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch s {
+	case "oldest":
+		return ConflictOldest, nil
+	case "priority":
+		return ConflictPriority, nil
+	case "drop":
+		return ConflictDrop, nil
+	}
+
+	return ConflictOldest, fmt.Errorf("unknown conflict policy %q", s)
+}
+
+// What's known about the object that owns a set of entries, for resolving
+// conflicts.
+type ObjectInfo struct {
+	CreationTimestamp time.Time
+	Priority          int
+}
+
+func (oi *ObjectInfo) Equals(other *ObjectInfo) bool {
+	return oi.CreationTimestamp.Equal(other.CreationTimestamp) && oi.Priority == other.Priority
+}
+
+// Winner is empty if the policy dropped the hostname from every object.
+type Conflict struct {
+	Hostname string
+	Winner   string
+	Losers   []string
+}
+
+// Only hostnames claimed by more than one object with different IPs or CNAME
+// targets are conflicts; objects agreeing on them can all keep publishing
+// them.
+// Conflicts are sorted by hostname.
+func (hf *HostsFile) Conflicts() []Conflict {
+	conflicts := []Conflict{}
+	for hostname, objectIds := range hf.hostnames {
+		if len(objectIds) < 2 {
+			continue
+		}
+
+		claimants := []string{}
+		ips := map[string]string{}
+		for objectId := range objectIds {
+			claimants = append(claimants, objectId)
+			ips[objectId] = hf.hostnameValues(objectId, hostname)
+		}
+
+		agreed := true
+		for _, objectId := range claimants {
+			agreed = agreed && ips[objectId] == ips[claimants[0]]
+		}
+		if agreed {
+			continue
+		}
+
+		sort.Slice(claimants, func(i, j int) bool {
+			return hf.precedes(claimants[i], claimants[j])
+		})
+
+		conflict := Conflict{hostname, claimants[0], claimants[1:]}
+		if hf.conflictPolicy == ConflictDrop {
+			conflict = Conflict{hostname, "", claimants}
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Hostname < conflicts[j].Hostname
+	})
+
+	return conflicts
+}
+
+// Whether objectId a takes precedence over objectId b.
+// Ties are broken by the object IDs themselves, so the outcome doesn't depend
+// on the order objects were seen in.
+func (hf *HostsFile) precedes(a, b string) bool {
+	ai, bi := hf.objects[a], hf.objects[b]
+
+	if hf.conflictPolicy == ConflictPriority && ai.Priority != bi.Priority {
+		return ai.Priority > bi.Priority
+	}
+
+	if !ai.CreationTimestamp.Equal(bi.CreationTimestamp) {
+		return ai.CreationTimestamp.Before(bi.CreationTimestamp)
+	}
+
+	return a < b
+}
+
+// Sorted, comma separated list of the IPs and CNAME targets the object
+// publishes the hostname to.
+func (hf *HostsFile) hostnameValues(objectId, hostname string) string {
+	values := []string{}
+	for _, entry := range hf.entries[objectId] {
+		for _, host := range entry.hosts {
+			if normalizeHostname(host) == hostname {
+				values = append(values, entry.ip)
+				break
+			}
+		}
+	}
+
+	for _, record := range hf.records[objectId] {
+		if record.rtype == RecordCNAME && normalizeHostname(record.name) == hostname {
+			values = append(values, "CNAME "+normalizeHostname(record.target))
+		}
+	}
+
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// Maps hostnames to the set of objects that may not publish them.
+func conflictLosers(conflicts []Conflict) map[string]map[string]bool {
+	losers := map[string]map[string]bool{}
+	for _, conflict := range conflicts {
+		losers[conflict.Hostname] = map[string]bool{}
+		for _, objectId := range conflict.Losers {
+			losers[conflict.Hostname][objectId] = true
+		}
+	}
+
+	return losers
+}
+
+// Hostnames are case insensitive, and may or may not be fully qualified.
+func normalizeHostname(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
@@ -0,0 +1,128 @@
+package hostsfile
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy ConflictPolicy
+	}{
+		{"oldest", ConflictOldest},
+		{"priority", ConflictPriority},
+		{"drop", ConflictDrop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseConflictPolicy(tt.name)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.policy, policy)
+		})
+	}
+
+	_, err := ParseConflictPolicy("newest")
+	assert.EqualError(t, err, "unknown conflict policy \"newest\"")
+}
+
+func conflictingTestHostsFile(policy ConflictPolicy) *HostsFile {
+	hf := NewHostsFile()
+	hf.SetConflictPolicy(policy)
+
+	now := time.Now()
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"google.com", "www.google.com"}})
+	hf.SetObjectInfo("abc", ObjectInfo{now, 0})
+	hf.SetHostsEntry("xyz", HostsEntry{"192.168.1.3", []string{"Google.com."}})
+	hf.SetObjectInfo("xyz", ObjectInfo{now.Add(-time.Hour), 0})
+	hf.SetHostsEntry("123", HostsEntry{"192.168.1.4", []string{"google.com"}})
+	hf.SetObjectInfo("123", ObjectInfo{now.Add(time.Hour), 10})
+
+	return hf
+}
+
+func TestHostsFileConflictsNone(t *testing.T) {
+	hf := NewHostsFile()
+
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"google.com"}})
+	hf.SetHostsEntries("xyz", []HostsEntry{
+		HostsEntry{"192.168.1.2", []string{"google.com."}},
+		HostsEntry{"192.168.1.3", []string{"bing.com"}},
+	})
+	hf.SetHostsEntries("123", []HostsEntry{
+		HostsEntry{"192.168.1.3", []string{"yahoo.com"}},
+		HostsEntry{"fd00::3", []string{"yahoo.com"}},
+	})
+
+	assert.Equal(t, []Conflict{}, hf.Conflicts())
+}
+
+func TestHostsFileConflictsOldest(t *testing.T) {
+	hf := conflictingTestHostsFile(ConflictOldest)
+
+	assert.Equal(t, []Conflict{Conflict{"google.com", "xyz", []string{"abc", "123"}}}, hf.Conflicts())
+	assert.Equal(t, "192.168.1.3\tGoogle.com.\n", objectLines(hf, "xyz"))
+	assert.Equal(t, "192.168.1.2\twww.google.com\n", objectLines(hf, "abc"))
+	assert.Equal(t, "", objectLines(hf, "123"))
+}
+
+func TestHostsFileConflictsPriority(t *testing.T) {
+	hf := conflictingTestHostsFile(ConflictPriority)
+
+	assert.Equal(t, []Conflict{Conflict{"google.com", "123", []string{"xyz", "abc"}}}, hf.Conflicts())
+}
+
+func TestHostsFileConflictsDrop(t *testing.T) {
+	hf := conflictingTestHostsFile(ConflictDrop)
+
+	assert.Equal(t, []Conflict{Conflict{"google.com", "", []string{"xyz", "abc", "123"}}}, hf.Conflicts())
+	assert.Equal(t, "192.168.1.2\twww.google.com\n", hf.String())
+}
+
+func TestHostsFileConflictsTies(t *testing.T) {
+	hf := NewHostsFile()
+
+	hf.SetHostsEntry("xyz", HostsEntry{"192.168.1.3", []string{"google.com"}})
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"google.com"}})
+
+	assert.Equal(t, []Conflict{Conflict{"google.com", "abc", []string{"xyz"}}}, hf.Conflicts())
+}
+
+func TestHostsFileConflictsResolved(t *testing.T) {
+	hf := conflictingTestHostsFile(ConflictOldest)
+
+	assert.True(t, hf.RemoveHostsEntry("xyz"))
+	assert.Equal(t, []Conflict{Conflict{"google.com", "abc", []string{"123"}}}, hf.Conflicts())
+
+	assert.True(t, hf.SetHostsEntry("123", HostsEntry{"192.168.1.4", []string{"bing.com"}}))
+	assert.Equal(t, []Conflict{}, hf.Conflicts())
+}
+
+func TestHostsFileSetObjectInfo(t *testing.T) {
+	hf := NewHostsFile()
+
+	now := time.Now()
+	assert.True(t, hf.SetObjectInfo("abc", ObjectInfo{now, 0}))
+	assert.False(t, hf.SetObjectInfo("abc", ObjectInfo{now, 0}))
+	assert.True(t, hf.SetObjectInfo("abc", ObjectInfo{now, 1}))
+	assert.True(t, hf.SetObjectInfo("abc", ObjectInfo{now.Add(time.Second), 1}))
+}
+
+// Output of a single object, after conflicts have been resolved.
+func objectLines(hf *HostsFile, objectId string) string {
+	losers := conflictLosers(hf.Conflicts())
+
+	rv := ""
+	for _, entry := range hf.entries[objectId] {
+		he := entry.without(objectId, losers)
+		if len(he.hosts) != 0 {
+			rv += he.String() + "\n"
+		}
+	}
+
+	return rv
+}
@@ -0,0 +1,77 @@
+package hostsfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dnsmasq configuration, either as address=/host/ip lines, or as
+// host-record=host,ip lines.
+// host-record can't express wildcards, so those are always written as
+// address lines. An address line for *.example.com also answers for
+// example.com itself.
+// address lines can also be followed by ptr-record lines for reverse lookups;
+// host-record lines already answer them.
+// Other records are written as cname, srv-host and txt-record lines.
+type DnsmasqRenderer struct {
+	options    RenderOptions
+	hostRecord bool
+}
+
+func (dr *DnsmasqRenderer) Render(snapshot Snapshot) (string, error) {
+	var sb strings.Builder
+
+	writeProvenanceHeader(&sb, dr.options, "#")
+
+	groups, records := hostnameRecords(snapshot.Groups)
+	for i, group := range groups {
+		writeProvenanceComment(&sb, dr.options, "#", group)
+
+		for _, record := range records[i] {
+			if IsWildcardHostname(record.hostname) {
+				sb.WriteString(fmt.Sprintf("address=/%s/%s\n", strings.TrimPrefix(record.hostname, "*."), record.ip))
+			} else if dr.hostRecord {
+				sb.WriteString(fmt.Sprintf("host-record=%s,%s\n", record.hostname, record.ip))
+			} else {
+				sb.WriteString(fmt.Sprintf("address=/%s/%s\n", record.hostname, record.ip))
+			}
+		}
+
+		for _, record := range group.Records {
+			sb.WriteString(dnsmasqRecordLine(record))
+		}
+	}
+
+	if dr.options.PtrRecords && !dr.hostRecord {
+		for _, record := range ptrRecords(snapshot.Groups) {
+			name, err := reverseName(record.ip)
+			if err != nil {
+				continue
+			}
+
+			writeProvenanceComment(&sb, dr.options, "#", EntryGroup{ObjectIds: record.objectIds})
+			sb.WriteString(fmt.Sprintf("ptr-record=%s,%s\n", name, record.hostname))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func dnsmasqRecordLine(record Record) string {
+	name := normalizeHostname(record.name)
+	switch record.rtype {
+	case RecordCNAME:
+		return fmt.Sprintf("cname=%s,%s\n", name, normalizeHostname(record.target))
+	case RecordSRV:
+		return fmt.Sprintf("srv-host=%s,%s,%d,%d,%d\n", name, normalizeHostname(record.target), record.port, record.priority, record.weight)
+	case RecordTXT:
+		quoted := []string{}
+		for _, text := range record.text {
+			quoted = append(quoted, strconv.Quote(text))
+		}
+		return fmt.Sprintf("txt-record=%s,%s\n", name, strings.Join(quoted, ","))
+	}
+
+	return ""
+}
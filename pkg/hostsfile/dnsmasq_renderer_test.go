@@ -0,0 +1,52 @@
+package hostsfile
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDnsmasqRendererAddress(t *testing.T) {
+	dr := DnsmasqRenderer{RenderOptions{}, false}
+
+	rv, err := dr.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "address=/google.com/192.168.1.2\naddress=/www.google.com/192.168.1.2\naddress=/google.com/fd00::2\naddress=/apps.google.com/192.168.1.2\n", rv)
+}
+
+func TestDnsmasqRendererHostRecord(t *testing.T) {
+	dr := DnsmasqRenderer{RenderOptions{}, true}
+
+	rv, err := dr.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "host-record=google.com,192.168.1.2\nhost-record=www.google.com,192.168.1.2\nhost-record=google.com,fd00::2\naddress=/apps.google.com/192.168.1.2\n", rv)
+}
+
+func TestDnsmasqRendererPtrRecords(t *testing.T) {
+	dr := DnsmasqRenderer{RenderOptions{PtrRecords: true}, false}
+
+	rv, err := dr.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "address=/google.com/192.168.1.2\naddress=/www.google.com/192.168.1.2\naddress=/google.com/fd00::2\naddress=/apps.google.com/192.168.1.2\n"+
+		"ptr-record=2.1.168.192.in-addr.arpa,google.com\n"+
+		"ptr-record=2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa,google.com\n", rv)
+
+	dr = DnsmasqRenderer{RenderOptions{PtrRecords: true}, true}
+
+	rv, err = dr.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.NotContains(t, rv, "ptr-record=")
+}
+
+func TestDnsmasqRendererRecords(t *testing.T) {
+	dr := DnsmasqRenderer{RenderOptions{}, false}
+
+	rv, err := dr.Render(testRecordsSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "address=/google.com/192.168.1.2\n"+
+		"cname=www.google.com,google.com\n"+
+		"srv-host=_http._tcp.google.com,google.com,80,0,5\n"+
+		"txt-record=google.com,\"v=spf1 -all\"\n", rv)
+}
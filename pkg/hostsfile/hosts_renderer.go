@@ -0,0 +1,114 @@
+package hostsfile
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tab separated hosts format, as read by /etc/hosts and Pi-hole.
+type HostsRenderer struct {
+	options RenderOptions
+}
+
+func (hr *HostsRenderer) Render(snapshot Snapshot) (string, error) {
+	var sb strings.Builder
+
+	writeProvenanceHeader(&sb, hr.options, "#")
+
+	// Hosts format has no way to express wildcards, or records other than
+	// addresses.
+	groups := []EntryGroup{}
+	for _, group := range snapshot.Groups {
+		entries := []HostsEntry{}
+		for _, hostEntry := range group.Entries {
+			he := hostEntry.withoutWildcards()
+			if len(he.hosts) != 0 {
+				entries = append(entries, *he)
+			}
+		}
+
+		if len(entries) != 0 {
+			groups = append(groups, EntryGroup{group.ObjectIds, entries, []Record{}})
+		}
+	}
+
+	if hr.options.CollapseByIp {
+		groups = collapseGroups(groups, hr.options.MaxHostsPerLine, hr.options.MaxLineLength)
+	}
+
+	for _, group := range groups {
+		writeProvenanceComment(&sb, hr.options, "#", group)
+
+		for _, he := range group.Entries {
+			sb.WriteString(he.String())
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Merges entries sharing an IP, dropping repeated hostnames, then splits them
+// back up into lines that fit the limits.
+// Each line becomes its own group, owned by every object that published any
+// of its hostnames.
+// IPs and hostnames are sorted, so the output is stable.
+func collapseGroups(groups []EntryGroup, maxHostsPerLine, maxLineLength int) []EntryGroup {
+	hostsByIp := map[string][]string{}
+	owners := map[string]map[string][]string{}
+	for _, group := range groups {
+		for _, entry := range group.Entries {
+			if _, ok := owners[entry.ip]; !ok {
+				owners[entry.ip] = map[string][]string{}
+			}
+
+			for _, host := range entry.hosts {
+				hostname := normalizeHostname(host)
+				if _, ok := owners[entry.ip][hostname]; !ok {
+					hostsByIp[entry.ip] = append(hostsByIp[entry.ip], host)
+				}
+				owners[entry.ip][hostname] = append(owners[entry.ip][hostname], group.ObjectIds...)
+			}
+		}
+	}
+
+	ips := []string{}
+	for ip := range hostsByIp {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	collapsed := []EntryGroup{}
+	for _, ip := range ips {
+		hosts := hostsByIp[ip]
+		sort.Strings(hosts)
+
+		lines := [][]string{}
+		line := []string{}
+		lineLength := len(ip)
+		for _, host := range hosts {
+			full := maxHostsPerLine > 0 && len(line) >= maxHostsPerLine
+			long := maxLineLength > 0 && lineLength+1+len(host) > maxLineLength
+			if len(line) != 0 && (full || long) {
+				lines = append(lines, line)
+				line = []string{}
+				lineLength = len(ip)
+			}
+
+			line = append(line, host)
+			lineLength += 1 + len(host)
+		}
+		lines = append(lines, line)
+
+		for _, line := range lines {
+			lineOwners := []string{}
+			for _, host := range line {
+				lineOwners = append(lineOwners, owners[ip][normalizeHostname(host)]...)
+			}
+
+			collapsed = append(collapsed, EntryGroup{uniqueSorted(lineOwners), []HostsEntry{*NewHostsEntry(ip, line)}, []Record{}})
+		}
+	}
+
+	return collapsed
+}
@@ -14,6 +14,14 @@ func NewHostsEntry(ip string, hosts []string) *HostsEntry {
 	return &he
 }
 
+func (he *HostsEntry) Ip() string {
+	return he.ip
+}
+
+func (he *HostsEntry) Hosts() []string {
+	return append([]string{}, he.hosts...)
+}
+
 func (he *HostsEntry) String() string {
 	return strings.Join(append([]string{he.ip}, he.hosts...), "\t")
 }
@@ -35,3 +43,26 @@ func (he *HostsEntry) Equals(other *HostsEntry) bool {
 
 	return true
 }
+
+func (he *HostsEntry) withoutWildcards() *HostsEntry {
+	hosts := []string{}
+	for _, host := range he.hosts {
+		if !IsWildcardHostname(host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return NewHostsEntry(he.ip, hosts)
+}
+
+// Leaves out the hostnames the object lost to other objects.
+func (he *HostsEntry) without(objectId string, losers map[string]map[string]bool) *HostsEntry {
+	hosts := []string{}
+	for _, host := range he.hosts {
+		if !losers[normalizeHostname(host)][objectId] {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return NewHostsEntry(he.ip, hosts)
+}
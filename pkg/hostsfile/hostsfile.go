@@ -1,57 +1,203 @@
 package hostsfile
 
 import (
-	"strings"
+	"sort"
 )
 
 type IHostsFile interface {
 	SetHostsEntry(objectId string, entry HostsEntry) bool
+	SetHostsEntries(objectId string, entries []HostsEntry) bool
+	SetRecords(objectId string, records []Record) bool
 	RemoveHostsEntry(objectId string) bool
+	SetObjectInfo(objectId string, info ObjectInfo) bool
 
+	Conflicts() []Conflict
+	Snapshot() Snapshot
 	String() string
 }
 
 type HostsFile struct {
-	entries map[string]*HostsEntry
+	entries map[string][]HostsEntry
+	records map[string][]Record
+	objects map[string]ObjectInfo
+
+	// Object IDs claiming each hostname, keyed by normalized hostname.
+	// CNAMEs claim their names too, since nothing else can be published
+	// alongside them.
+	hostnames      map[string]map[string]bool
+	conflictPolicy ConflictPolicy
 }
 
 func NewHostsFile() *HostsFile {
 	hf := HostsFile{
-		map[string]*HostsEntry{},
+		map[string][]HostsEntry{},
+		map[string][]Record{},
+		map[string]ObjectInfo{},
+		map[string]map[string]bool{},
+		ConflictOldest,
 	}
 
 	return &hf
 }
 
 func (hf *HostsFile) SetHostsEntry(objectId string, entry HostsEntry) bool {
+	return hf.SetHostsEntries(objectId, []HostsEntry{entry})
+}
+
+// Some objects (nodes with several addresses, dual-stack pods) map to more
+// than one line in the hostsfile, so an object owns a list of entries.
+func (hf *HostsFile) SetHostsEntries(objectId string, entries []HostsEntry) bool {
+	updated := false
+
+	if existing, ok := hf.entries[objectId]; !ok || !entriesEqual(existing, entries) {
+		updated = true
+		hf.unindex(objectId)
+		hf.entries[objectId] = entries
+		hf.index(objectId)
+	}
+
+	return updated
+}
+
+// Objects publishing anything other than addresses own a list of records
+// too, alongside their entries.
+func (hf *HostsFile) SetRecords(objectId string, records []Record) bool {
 	updated := false
 
-	if existing, ok := hf.entries[objectId]; !ok || !existing.Equals(&entry) {
+	if existing, ok := hf.records[objectId]; !ok || !recordsEqual(existing, records) {
 		updated = true
-		hf.entries[objectId] = &entry
+		hf.unindex(objectId)
+		hf.records[objectId] = records
+		hf.index(objectId)
 	}
 
 	return updated
 }
 
+// Removes the object's records along with its entries.
 func (hf *HostsFile) RemoveHostsEntry(objectId string) bool {
+	_, hasEntries := hf.entries[objectId]
+	_, hasRecords := hf.records[objectId]
+	if !hasEntries && !hasRecords {
+		return false
+	}
+
+	hf.unindex(objectId)
+	delete(hf.entries, objectId)
+	delete(hf.records, objectId)
+	delete(hf.objects, objectId)
+	return true
+}
+
+// Objects without any info set are treated as the oldest possible objects,
+// with no priority.
+func (hf *HostsFile) SetObjectInfo(objectId string, info ObjectInfo) bool {
 	updated := false
 
-	if _, ok := hf.entries[objectId]; ok {
+	if existing, ok := hf.objects[objectId]; !ok || !existing.Equals(&info) {
 		updated = true
-		delete(hf.entries, objectId)
+		hf.objects[objectId] = info
 	}
 
 	return updated
 }
 
+func (hf *HostsFile) SetConflictPolicy(policy ConflictPolicy) {
+	hf.conflictPolicy = policy
+}
+
+// Hosts format, with the default render options.
 func (hf *HostsFile) String() string {
-	var sb strings.Builder
+	renderer := HostsRenderer{}
+	rv, _ := renderer.Render(hf.Snapshot())
+	return rv
+}
+
+// Every object's entries and records, less hostnames lost to conflicts.
+// Objects are sorted by ID, so that output is stable.
+func (hf *HostsFile) Snapshot() Snapshot {
+	snapshot := Snapshot{[]EntryGroup{}}
+
+	objectIds := []string{}
+	for objectId := range hf.entries {
+		objectIds = append(objectIds, objectId)
+	}
+	for objectId := range hf.records {
+		if _, ok := hf.entries[objectId]; !ok {
+			objectIds = append(objectIds, objectId)
+		}
+	}
+	sort.Strings(objectIds)
+
+	losers := conflictLosers(hf.Conflicts())
+	for _, objectId := range objectIds {
+		group := EntryGroup{[]string{objectId}, []HostsEntry{}, []Record{}}
+		for _, hostEntry := range hf.entries[objectId] {
+			he := hostEntry.without(objectId, losers)
+			if len(he.hosts) != 0 {
+				group.Entries = append(group.Entries, *he)
+			}
+		}
+
+		for _, record := range hf.records[objectId] {
+			if record.rtype != RecordCNAME || !losers[normalizeHostname(record.name)][objectId] {
+				group.Records = append(group.Records, record)
+			}
+		}
+
+		if len(group.Entries) != 0 || len(group.Records) != 0 {
+			snapshot.Groups = append(snapshot.Groups, group)
+		}
+	}
+
+	return snapshot
+}
+
+func (hf *HostsFile) index(objectId string) {
+	for _, hostname := range hf.claimedHostnames(objectId) {
+		if _, ok := hf.hostnames[hostname]; !ok {
+			hf.hostnames[hostname] = map[string]bool{}
+		}
+		hf.hostnames[hostname][objectId] = true
+	}
+}
+
+func (hf *HostsFile) unindex(objectId string) {
+	for _, hostname := range hf.claimedHostnames(objectId) {
+		delete(hf.hostnames[hostname], objectId)
+		if len(hf.hostnames[hostname]) == 0 {
+			delete(hf.hostnames, hostname)
+		}
+	}
+}
+
+func (hf *HostsFile) claimedHostnames(objectId string) []string {
+	hostnames := []string{}
+	for _, entry := range hf.entries[objectId] {
+		for _, host := range entry.hosts {
+			hostnames = append(hostnames, normalizeHostname(host))
+		}
+	}
+
+	for _, record := range hf.records[objectId] {
+		if record.rtype == RecordCNAME {
+			hostnames = append(hostnames, normalizeHostname(record.name))
+		}
+	}
+
+	return hostnames
+}
+
+func entriesEqual(a, b []HostsEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
 
-	for _, hostEntry := range hf.entries {
-		sb.WriteString(hostEntry.String())
-		sb.WriteString("\n")
+	for i := range a {
+		if !a[i].Equals(&b[i]) {
+			return false
+		}
 	}
 
-	return sb.String()
+	return true
 }
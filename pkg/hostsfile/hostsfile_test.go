@@ -21,6 +21,20 @@ func TestHostsFileSetHostsEntry(t *testing.T) {
 	assert.True(t, hf.SetHostsEntry("abc", he2))
 }
 
+func TestHostsFileSetHostsEntries(t *testing.T) {
+	hf := NewHostsFile()
+
+	he1 := HostsEntry{"192.168.1.2", []string{"google.com"}}
+	he2 := HostsEntry{"192.168.1.3", []string{"google.com"}}
+
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he1, he2}))
+	assert.False(t, hf.SetHostsEntries("abc", []HostsEntry{he1, he2}))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he2, he1}))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{he1}))
+	assert.False(t, hf.SetHostsEntry("abc", he1))
+	assert.True(t, hf.SetHostsEntries("abc", []HostsEntry{}))
+}
+
 func TestHostsFileRemoveHostsEntry(t *testing.T) {
 	hf := NewHostsFile()
 
@@ -53,3 +67,72 @@ func TestHostsFileString(t *testing.T) {
 	assert.True(t, strings.Contains(hf.String(), he1.String()))
 	assert.True(t, strings.Contains(hf.String(), he2.String()))
 }
+
+func TestHostsFileStringWildcards(t *testing.T) {
+	hf := NewHostsFile()
+
+	he1 := HostsEntry{"192.168.1.2", []string{"google.com", "*.google.com"}}
+	he2 := HostsEntry{"192.168.1.3", []string{"*.bing.com"}}
+
+	hf.SetHostsEntries("abc", []HostsEntry{he1, he2})
+
+	assert.Equal(t, "192.168.1.2\tgoogle.com\n", hf.String())
+}
+
+func TestHostsFileStringCollapsed(t *testing.T) {
+	hf := NewHostsFile()
+	hr := HostsRenderer{RenderOptions{CollapseByIp: true}}
+
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"www.google.com", "google.com"}})
+	hf.SetHostsEntries("xyz", []HostsEntry{
+		HostsEntry{"192.168.1.3", []string{"bing.com"}},
+		HostsEntry{"192.168.1.2", []string{"Google.com.", "mail.google.com"}},
+	})
+
+	assert.Equal(t, "192.168.1.2\tgoogle.com\tmail.google.com\twww.google.com\n192.168.1.3\tbing.com\n", render(t, &hr, hf.Snapshot()))
+
+	// Ownership is kept, so removing an object only removes its hostnames.
+	hf.RemoveHostsEntry("abc")
+	assert.Equal(t, "192.168.1.2\tGoogle.com.\tmail.google.com\n192.168.1.3\tbing.com\n", render(t, &hr, hf.Snapshot()))
+}
+
+func TestHostsFileStringCollapsedLimits(t *testing.T) {
+	hf := NewHostsFile()
+
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"a.com", "b.com", "c.com", "d.com", "e.com"}})
+
+	hr := HostsRenderer{RenderOptions{CollapseByIp: true, MaxHostsPerLine: 2}}
+	assert.Equal(t, "192.168.1.2\ta.com\tb.com\n192.168.1.2\tc.com\td.com\n192.168.1.2\te.com\n", render(t, &hr, hf.Snapshot()))
+
+	hr = HostsRenderer{RenderOptions{CollapseByIp: true, MaxLineLength: 30}}
+	assert.Equal(t, "192.168.1.2\ta.com\tb.com\tc.com\n192.168.1.2\td.com\te.com\n", render(t, &hr, hf.Snapshot()))
+
+	// Hostnames too long to fit the limit on their own still get a line.
+	hr = HostsRenderer{RenderOptions{CollapseByIp: true, MaxLineLength: 10}}
+	assert.Equal(t, "192.168.1.2\ta.com\n192.168.1.2\tb.com\n192.168.1.2\tc.com\n192.168.1.2\td.com\n192.168.1.2\te.com\n", render(t, &hr, hf.Snapshot()))
+}
+
+func TestHostsFileStringProvenance(t *testing.T) {
+	hf := NewHostsFile()
+	hr := HostsRenderer{RenderOptions{Provenance: true, Version: "v1.2.3"}}
+
+	hf.SetHostsEntry("networkingv1.ingress/default/some-ingress", HostsEntry{"192.168.1.2", []string{"google.com"}})
+	hf.SetHostsEntries("v1.node/some-node", []HostsEntry{
+		HostsEntry{"192.168.1.3", []string{"bing.com"}},
+		HostsEntry{"192.168.1.2", []string{"www.google.com"}},
+	})
+
+	lines := strings.SplitN(render(t, &hr, hf.Snapshot()), "\n", 2)
+	assert.Regexp(t, "^# Generated by hostsfile-generator v1.2.3 at \\d{4}-\\d{2}-\\d{2}T\\d{2}:\\d{2}:\\d{2}Z$", lines[0])
+	assert.Equal(t, "# networkingv1.ingress/default/some-ingress\n192.168.1.2\tgoogle.com\n# v1.node/some-node\n192.168.1.3\tbing.com\n192.168.1.2\twww.google.com\n", lines[1])
+
+	hr = HostsRenderer{RenderOptions{Provenance: true, Version: "v1.2.3", CollapseByIp: true}}
+
+	lines = strings.SplitN(render(t, &hr, hf.Snapshot()), "\n", 2)
+	assert.Equal(t, "# networkingv1.ingress/default/some-ingress, v1.node/some-node\n192.168.1.2\tgoogle.com\twww.google.com\n# v1.node/some-node\n192.168.1.3\tbing.com\n", lines[1])
+
+	// Comments don't get in the way of parsing the output again.
+	entries, err := Parse(strings.NewReader(render(t, &hr, hf.Snapshot())))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+}
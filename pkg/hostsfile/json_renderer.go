@@ -0,0 +1,62 @@
+package hostsfile
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The snapshot as a JSON document, grouped by the objects that published each
+// entry.
+type JSONRenderer struct {
+	options RenderOptions
+}
+
+type jsonDocument struct {
+	Version     string       `json:"version,omitempty"`
+	GeneratedAt string       `json:"generatedAt,omitempty"`
+	Objects     []jsonObject `json:"objects"`
+}
+
+type jsonObject struct {
+	ObjectIds []string     `json:"objectIds"`
+	Entries   []jsonEntry  `json:"entries"`
+	Records   []jsonRecord `json:"records"`
+}
+
+type jsonEntry struct {
+	Ip        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// Data is written the way it would be in a zone file.
+type jsonRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+func (jr *JSONRenderer) Render(snapshot Snapshot) (string, error) {
+	document := jsonDocument{Objects: []jsonObject{}}
+	if jr.options.Provenance {
+		document.Version = jr.options.Version
+		document.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	for _, group := range snapshot.Groups {
+		object := jsonObject{group.ObjectIds, []jsonEntry{}, []jsonRecord{}}
+		for _, entry := range group.Entries {
+			object.Entries = append(object.Entries, jsonEntry{entry.ip, entry.hosts})
+		}
+		for _, record := range group.Records {
+			object.Records = append(object.Records, jsonRecord{record.name, string(record.rtype), record.Data()})
+		}
+		document.Objects = append(document.Objects, object)
+	}
+
+	rv, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(rv) + "\n", nil
+}
@@ -0,0 +1,62 @@
+package hostsfile
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRenderer(t *testing.T) {
+	jr := JSONRenderer{RenderOptions{}}
+
+	rv, err := jr.Render(testSnapshot())
+	assert.Nil(t, err)
+
+	var document jsonDocument
+	assert.Nil(t, json.Unmarshal([]byte(rv), &document))
+	assert.Equal(t, jsonDocument{
+		Objects: []jsonObject{
+			jsonObject{[]string{"abc"}, []jsonEntry{
+				jsonEntry{"192.168.1.2", []string{"google.com", "www.google.com"}},
+				jsonEntry{"fd00::2", []string{"google.com"}},
+			}, []jsonRecord{}},
+			jsonObject{[]string{"xyz"}, []jsonEntry{
+				jsonEntry{"192.168.1.2", []string{"*.apps.google.com"}},
+			}, []jsonRecord{}},
+		},
+	}, document)
+}
+
+func TestJSONRendererEmpty(t *testing.T) {
+	jr := JSONRenderer{RenderOptions{Provenance: true, Version: "v1.2.3"}}
+
+	rv, err := jr.Render(Snapshot{})
+	assert.Nil(t, err)
+
+	var document jsonDocument
+	assert.Nil(t, json.Unmarshal([]byte(rv), &document))
+	assert.Equal(t, "v1.2.3", document.Version)
+	assert.NotEqual(t, "", document.GeneratedAt)
+	assert.Equal(t, []jsonObject{}, document.Objects)
+}
+
+func TestJSONRendererRecords(t *testing.T) {
+	jr := JSONRenderer{RenderOptions{}}
+
+	rv, err := jr.Render(testRecordsSnapshot())
+	assert.Nil(t, err)
+
+	var document jsonDocument
+	assert.Nil(t, json.Unmarshal([]byte(rv), &document))
+	assert.Equal(t, []jsonRecord{
+		jsonRecord{"www.google.com.", "CNAME", "google.com."},
+		jsonRecord{"_http._tcp.google.com.", "SRV", "0 5 80 google.com."},
+	}, document.Objects[0].Records)
+	assert.Equal(t, []jsonRecord{
+		jsonRecord{"www.google.com", "CNAME", "google.com."},
+		jsonRecord{"google.com", "TXT", "\"v=spf1 -all\""},
+	}, document.Objects[1].Records)
+}
@@ -0,0 +1,92 @@
+package hostsfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ParseError struct {
+	Line   int
+	Column int
+	Reason string
+}
+
+func (pe *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", pe.Line, pe.Column, pe.Reason)
+}
+
+// All bad lines in a file, so they can be reported together.
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	messages := []string{}
+	for _, e := range pe {
+		messages = append(messages, e.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Reads hosts-format entries, one per line.
+// Blank lines and comments (whole line or trailing) are ignored, and fields can
+// be separated by any mix of tabs and spaces.
+// Bad lines don't stop parsing; every entry that could be read is returned
+// along with a ParseErrors describing the lines that couldn't.
+func Parse(r io.Reader) ([]HostsEntry, error) {
+	entries := []HostsEntry{}
+	errs := ParseErrors{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		he, err := parseLine(scanner.Text())
+		if err != nil {
+			err.Line = lineNumber
+			errs = append(errs, err)
+			continue
+		}
+
+		if he != nil {
+			entries = append(entries, *he)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return entries, err
+	}
+
+	if len(errs) != 0 {
+		return entries, errs
+	}
+
+	return entries, nil
+}
+
+// Returns nil without an error for lines that don't hold an entry.
+func parseLine(line string) (*HostsEntry, *ParseError) {
+	if i := strings.Index(line, "#"); i != -1 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	ip := fields[0]
+	column := strings.Index(line, ip) + 1
+
+	if err := ValidateIp(ip); err != nil {
+		return nil, &ParseError{Column: column, Reason: err.Error()}
+	}
+
+	if len(fields) == 1 {
+		return nil, &ParseError{Column: column + len(ip), Reason: fmt.Sprintf("no hostnames for %s", ip)}
+	}
+
+	return NewHostsEntry(ip, fields[1:]), nil
+}
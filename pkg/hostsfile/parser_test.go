@@ -0,0 +1,71 @@
+package hostsfile
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		name     string
+		contents string
+		entries  []HostsEntry
+	}{
+		{"Empty", "", []HostsEntry{}},
+		{"Tabs", "192.168.1.2\tgoogle.com\twww.google.com\n", []HostsEntry{{"192.168.1.2", []string{"google.com", "www.google.com"}}}},
+		{"Spaces", "192.168.1.2   google.com www.google.com", []HostsEntry{{"192.168.1.2", []string{"google.com", "www.google.com"}}}},
+		{"Mixed Whitespace", "  192.168.1.2 \t google.com\t \twww.google.com  \n", []HostsEntry{{"192.168.1.2", []string{"google.com", "www.google.com"}}}},
+		{"Comments", "# A comment\n192.168.1.2\tgoogle.com\n  # Indented comment\n", []HostsEntry{{"192.168.1.2", []string{"google.com"}}}},
+		{"Inline Comments", "192.168.1.2\tgoogle.com # www.google.com\n", []HostsEntry{{"192.168.1.2", []string{"google.com"}}}},
+		{"Blank Lines", "\n192.168.1.2\tgoogle.com\n\n\t\n192.168.1.3\tbing.com\n", []HostsEntry{{"192.168.1.2", []string{"google.com"}}, {"192.168.1.3", []string{"bing.com"}}}},
+		{"IPv6", "::1\tlocalhost\nfe80::1%eth0\trouter\n", []HostsEntry{{"::1", []string{"localhost"}}, {"fe80::1%eth0", []string{"router"}}}},
+		{"Windows Line Endings", "192.168.1.2\tgoogle.com\r\n", []HostsEntry{{"192.168.1.2", []string{"google.com"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Parse(strings.NewReader(tt.contents))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.entries, entries)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	contents := "192.168.1.2\tgoogle.com\n  192.168.1\tbing.com\n192.168.1.3 # bing.com\n192.168.1.4\tyahoo.com\n"
+
+	entries, err := Parse(strings.NewReader(contents))
+	assert.Equal(t, []HostsEntry{{"192.168.1.2", []string{"google.com"}}, {"192.168.1.4", []string{"yahoo.com"}}}, entries)
+
+	errs, ok := err.(ParseErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ParseErrors{
+		&ParseError{2, 3, "invalid IP address \"192.168.1\""},
+		&ParseError{3, 12, "no hostnames for 192.168.1.3"},
+	}, errs)
+	assert.Equal(t, "line 2, column 3: invalid IP address \"192.168.1\"; line 3, column 12: no hostnames for 192.168.1.3", err.Error())
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	hf := NewHostsFile()
+
+	he1 := HostsEntry{"192.168.1.2", []string{"google.com"}}
+	he2 := HostsEntry{"192.168.1.3", []string{"bing.com", "www.bing.com"}}
+	he3 := HostsEntry{"fd00::3", []string{"bing.com"}}
+
+	hf.SetHostsEntry("abc", he1)
+	hf.SetHostsEntries("xyz", []HostsEntry{he2, he3})
+
+	entries, err := Parse(strings.NewReader(hf.String()))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []HostsEntry{he1, he2, he3}, entries)
+
+	for _, he := range entries {
+		parsed, err := Parse(strings.NewReader(he.String()))
+		assert.NoError(t, err)
+		assert.Equal(t, []HostsEntry{he}, parsed)
+	}
+}
@@ -0,0 +1,96 @@
+package hostsfile
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// A single reverse record for an IP, along with the objects that published the
+// hostname it points to.
+type ptrRecord struct {
+	ip        string
+	hostname  string
+	objectIds []string
+}
+
+// Picks one hostname for each IP, since resolvers only expect one answer to a
+// reverse lookup.
+// Wildcards are never picked. Otherwise, the name with the fewest labels wins,
+// then the shortest one, then the first alphabetically, so the same set of
+// names always gives the same answer.
+// Records are sorted by IP.
+func ptrRecords(groups []EntryGroup) []ptrRecord {
+	canonical := map[string]*ptrRecord{}
+	for _, group := range groups {
+		for _, entry := range group.Entries {
+			for _, host := range entry.hosts {
+				hostname := normalizeHostname(host)
+				if IsWildcardHostname(hostname) {
+					continue
+				}
+
+				current, ok := canonical[entry.ip]
+				if !ok || preferredPtrHostname(hostname, current.hostname) {
+					canonical[entry.ip] = &ptrRecord{entry.ip, hostname, group.ObjectIds}
+				} else if hostname == current.hostname {
+					current.objectIds = uniqueSorted(append(current.objectIds, group.ObjectIds...))
+				}
+			}
+		}
+	}
+
+	records := []ptrRecord{}
+	for _, record := range canonical {
+		records = append(records, *record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		ipi, ipj := net.ParseIP(stripZone(records[i].ip)), net.ParseIP(stripZone(records[j].ip))
+		if c := bytes.Compare(ipi.To16(), ipj.To16()); c != 0 {
+			return c < 0
+		}
+
+		return records[i].ip < records[j].ip
+	})
+
+	return records
+}
+
+func preferredPtrHostname(hostname, current string) bool {
+	labels, currentLabels := strings.Count(hostname, "."), strings.Count(current, ".")
+	if labels != currentLabels {
+		return labels < currentLabels
+	}
+
+	if len(hostname) != len(current) {
+		return len(hostname) < len(current)
+	}
+
+	return hostname < current
+}
+
+// The name a reverse lookup of the IP asks for, without a trailing dot.
+func reverseName(ip string) (string, error) {
+	parsed := net.ParseIP(stripZone(ip))
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP %q", ip)
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+
+	nibbles := []string{}
+	for i := len(parsed) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x.%x", parsed[i]&0xf, parsed[i]>>4))
+	}
+
+	return strings.Join(nibbles, ".") + ".ip6.arpa", nil
+}
+
+func stripZone(ip string) string {
+	return strings.SplitN(ip, "%", 2)[0]
+}
@@ -0,0 +1,48 @@
+package hostsfile
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseName(t *testing.T) {
+	name, err := reverseName("192.168.1.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.1.168.192.in-addr.arpa", name)
+
+	name, err = reverseName("fd00::2")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa", name)
+
+	name, err = reverseName("fe80::1%eth0")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.e.f.ip6.arpa", name)
+
+	_, err = reverseName("192.168.1")
+	assert.Error(t, err)
+}
+
+func TestPtrRecords(t *testing.T) {
+	groups := []EntryGroup{
+		EntryGroup{[]string{"abc"}, []HostsEntry{
+			HostsEntry{"192.168.1.10", []string{"some-ingress.internal.aleemhaji.com.", "*.internal.aleemhaji.com"}},
+			HostsEntry{"fd00::2", []string{"b.internal.aleemhaji.com"}},
+		}, nil},
+		EntryGroup{[]string{"def"}, []HostsEntry{
+			HostsEntry{"192.168.1.10", []string{"other.internal.aleemhaji.com", "ingress.aleemhaji.com"}},
+			HostsEntry{"192.168.1.9", []string{"*.apps.internal.aleemhaji.com"}},
+		}, nil},
+		EntryGroup{[]string{"xyz"}, []HostsEntry{
+			HostsEntry{"192.168.1.10", []string{"Ingress.aleemhaji.com"}},
+			HostsEntry{"fd00::2", []string{"a.internal.aleemhaji.com"}},
+		}, nil},
+	}
+
+	assert.Equal(t, []ptrRecord{
+		ptrRecord{"192.168.1.10", "ingress.aleemhaji.com", []string{"def", "xyz"}},
+		ptrRecord{"fd00::2", "a.internal.aleemhaji.com", []string{"xyz"}},
+	}, ptrRecords(groups))
+}
@@ -0,0 +1,138 @@
+package hostsfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type RecordType string
+
+const (
+	RecordCNAME RecordType = "CNAME"
+	RecordSRV   RecordType = "SRV"
+	RecordTXT   RecordType = "TXT"
+)
+
+// Records other than addresses, which hosts format can't hold.
+// Target is used by CNAME and SRV records, priority, weight and port only by
+// SRV records, and text only by TXT records.
+type Record struct {
+	name   string
+	rtype  RecordType
+	target string
+
+	priority uint16
+	weight   uint16
+	port     uint16
+
+	text []string
+}
+
+func NewCNAMERecord(name, target string) *Record {
+	r := Record{name: name, rtype: RecordCNAME, target: target}
+	return &r
+}
+
+func NewSRVRecord(name string, priority, weight, port uint16, target string) *Record {
+	r := Record{name: name, rtype: RecordSRV, target: target, priority: priority, weight: weight, port: port}
+	return &r
+}
+
+func NewTXTRecord(name string, text []string) *Record {
+	r := Record{name: name, rtype: RecordTXT, text: text}
+	return &r
+}
+
+func (r *Record) Name() string {
+	return r.name
+}
+
+func (r *Record) Type() RecordType {
+	return r.rtype
+}
+
+func (r *Record) Target() string {
+	return r.target
+}
+
+func (r *Record) Priority() uint16 {
+	return r.priority
+}
+
+func (r *Record) Weight() uint16 {
+	return r.weight
+}
+
+func (r *Record) Port() uint16 {
+	return r.port
+}
+
+func (r *Record) Text() []string {
+	return append([]string{}, r.text...)
+}
+
+// The record's data as written in a zone file, with names fully qualified.
+func (r *Record) Data() string {
+	switch r.rtype {
+	case RecordCNAME:
+		return fqdn(r.target)
+	case RecordSRV:
+		return fmt.Sprintf("%d %d %d %s", r.priority, r.weight, r.port, fqdn(r.target))
+	case RecordTXT:
+		quoted := []string{}
+		for _, text := range r.text {
+			quoted = append(quoted, strconv.Quote(text))
+		}
+		return strings.Join(quoted, " ")
+	}
+
+	return ""
+}
+
+func (r *Record) String() string {
+	return fmt.Sprintf("%s\t%s\t%s", r.name, r.rtype, r.Data())
+}
+
+func (r *Record) Equals(other *Record) bool {
+	if r.name != other.name || r.rtype != other.rtype || r.target != other.target {
+		return false
+	}
+
+	if r.priority != other.priority || r.weight != other.weight || r.port != other.port {
+		return false
+	}
+
+	if len(r.text) != len(other.text) {
+		return false
+	}
+
+	for i, text := range r.text {
+		if text != other.text[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Identifies the record regardless of how its names are written, so that it
+// can be compared against records published by other objects.
+func (r *Record) key() string {
+	normalized := Record{normalizeHostname(r.name), r.rtype, normalizeHostname(r.target), r.priority, r.weight, r.port, r.text}
+	return normalized.String()
+}
+
+func recordsEqual(a, b []Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equals(&b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+package hostsfile
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordData(t *testing.T) {
+	assert.Equal(t, "google.com.", NewCNAMERecord("www.google.com", "google.com").Data())
+	assert.Equal(t, "10 5 443 google.com.", NewSRVRecord("_https._tcp.google.com", 10, 5, 443, "google.com.").Data())
+	assert.Equal(t, "\"v=spf1 -all\" \"say \\\"hi\\\"\"", NewTXTRecord("google.com", []string{"v=spf1 -all", "say \"hi\""}).Data())
+}
+
+func TestRecordString(t *testing.T) {
+	assert.Equal(t, "www.google.com\tCNAME\tgoogle.com.", NewCNAMERecord("www.google.com", "google.com").String())
+}
+
+func TestRecordEqual(t *testing.T) {
+	r1 := NewTXTRecord("google.com", []string{"a", "b"})
+	r2 := NewTXTRecord("google.com", []string{"a", "b"})
+	r3 := NewTXTRecord("google.com", []string{"a"})
+	r4 := NewSRVRecord("_http._tcp.google.com", 0, 0, 80, "google.com")
+	r5 := NewSRVRecord("_http._tcp.google.com", 0, 0, 8080, "google.com")
+
+	assert.True(t, r1.Equals(r2))
+	assert.False(t, r1.Equals(r3))
+	assert.False(t, r4.Equals(r5))
+	assert.False(t, r1.Equals(r4))
+}
+
+func TestRecordKey(t *testing.T) {
+	assert.Equal(t, NewCNAMERecord("WWW.google.com.", "google.com.").key(), NewCNAMERecord("www.google.com", "Google.com").key())
+	assert.NotEqual(t, NewTXTRecord("google.com", []string{"A"}).key(), NewTXTRecord("google.com", []string{"a"}).key())
+}
+
+func TestHostsFileSetRecords(t *testing.T) {
+	hf := NewHostsFile()
+
+	r1 := NewCNAMERecord("www.google.com", "google.com")
+	r2 := NewTXTRecord("google.com", []string{"v=spf1 -all"})
+
+	assert.True(t, hf.SetRecords("abc", []Record{*r1}))
+	assert.False(t, hf.SetRecords("abc", []Record{*r1}))
+	assert.True(t, hf.SetRecords("abc", []Record{*r1, *r2}))
+	assert.Equal(t, Snapshot{[]EntryGroup{EntryGroup{[]string{"abc"}, []HostsEntry{}, []Record{*r1, *r2}}}}, hf.Snapshot())
+
+	assert.True(t, hf.RemoveHostsEntry("abc"))
+	assert.False(t, hf.RemoveHostsEntry("abc"))
+	assert.Equal(t, Snapshot{[]EntryGroup{}}, hf.Snapshot())
+}
+
+func TestHostsFileConflictsCNAME(t *testing.T) {
+	hf := NewHostsFile()
+
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"www.google.com"}})
+	hf.SetRecords("xyz", []Record{*NewCNAMERecord("www.google.com.", "google.com"), *NewTXTRecord("www.google.com", []string{"a"})})
+	assert.Equal(t, []Conflict{Conflict{"www.google.com", "abc", []string{"xyz"}}}, hf.Conflicts())
+
+	// The TXT record doesn't conflict with anything.
+	snapshot := hf.Snapshot()
+	assert.Equal(t, []Record{*NewTXTRecord("www.google.com", []string{"a"})}, snapshot.Groups[1].Records)
+
+	// Objects agreeing on the target can both publish it.
+	hf.RemoveHostsEntry("abc")
+	hf.SetRecords("abc", []Record{*NewCNAMERecord("WWW.google.com", "google.com.")})
+	assert.Equal(t, []Conflict{}, hf.Conflicts())
+}
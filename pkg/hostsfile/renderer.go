@@ -0,0 +1,155 @@
+package hostsfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The state of a hosts file at a point in time, as handed to renderers.
+// Conflicts have already been resolved, but wildcards are left in for the
+// renderers that can express them.
+type Snapshot struct {
+	Groups []EntryGroup
+}
+
+// Entries and records along with the objects that published them.
+type EntryGroup struct {
+	ObjectIds []string
+	Entries   []HostsEntry
+	Records   []Record
+}
+
+type Renderer interface {
+	Render(snapshot Snapshot) (string, error)
+}
+
+// Options shared by the built in renderers.
+// Collapsing and line limits only apply to hosts format.
+// Collapsing writes a single line per IP with every hostname published to it,
+// regardless of which object published it. The limits split long lines for
+// resolvers that can't handle them; zero means no limit.
+// Provenance adds a header saying when and by what version the output was
+// generated, and a comment before each group of lines naming the objects they
+// came from.
+type RenderOptions struct {
+	CollapseByIp    bool
+	MaxHostsPerLine int
+	MaxLineLength   int
+
+	Provenance bool
+	Version    string
+
+	// Adds ptr-record lines to dnsmasq address output, since address lines
+	// don't answer reverse lookups.
+	PtrRecords bool
+
+	// Contents of the template used by the template format.
+	Template string
+
+	Zone ZoneOptions
+}
+
+// Starts the header written with Provenance, after the format's comment
+// characters. The rest of the header changes every time it's rendered.
+const ProvenanceHeader = "Generated by hostsfile-generator"
+
+var RendererFormats []string = []string{"hosts", "dnsmasq-address", "dnsmasq-host-record", "unbound", "json", "template", "zone", "reverse-zone"}
+
+func NewRenderer(format string, options RenderOptions) (Renderer, error) {
+	switch format {
+	case "hosts":
+		return &HostsRenderer{options}, nil
+	case "dnsmasq-address":
+		return &DnsmasqRenderer{options, false}, nil
+	case "dnsmasq-host-record":
+		return &DnsmasqRenderer{options, true}, nil
+	case "unbound":
+		return &UnboundRenderer{options}, nil
+	case "json":
+		return &JSONRenderer{options}, nil
+	case "template":
+		return NewTemplateRenderer(options)
+	case "zone":
+		return NewZoneRenderer(options)
+	case "reverse-zone":
+		return NewReverseZoneRenderer(options)
+	}
+
+	return nil, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(RendererFormats, ", "))
+}
+
+// One line per hostname and IP, for formats that can't hold several hostnames
+// on one line.
+// Pairs already written by an earlier group are left out, and so are repeated
+// records, as are groups with nothing left.
+type hostnameRecord struct {
+	hostname string
+	ip       string
+}
+
+func hostnameRecords(groups []EntryGroup) ([]EntryGroup, [][]hostnameRecord) {
+	seen := map[hostnameRecord]bool{}
+	seenRecords := map[string]bool{}
+
+	kept := []EntryGroup{}
+	records := [][]hostnameRecord{}
+	for _, group := range groups {
+		groupRecords := []hostnameRecord{}
+		for _, entry := range group.Entries {
+			for _, host := range entry.hosts {
+				record := hostnameRecord{normalizeHostname(host), entry.ip}
+				if !seen[record] {
+					seen[record] = true
+					groupRecords = append(groupRecords, record)
+				}
+			}
+		}
+
+		otherRecords := []Record{}
+		for _, record := range group.Records {
+			if key := record.key(); !seenRecords[key] {
+				seenRecords[key] = true
+				otherRecords = append(otherRecords, record)
+			}
+		}
+
+		if len(groupRecords) != 0 || len(otherRecords) != 0 {
+			kept = append(kept, EntryGroup{group.ObjectIds, group.Entries, otherRecords})
+			records = append(records, groupRecords)
+		}
+	}
+
+	return kept, records
+}
+
+// Provenance is written as comments, starting with whatever the format uses.
+func writeProvenanceHeader(sb *strings.Builder, options RenderOptions, comment string) {
+	if options.Provenance {
+		sb.WriteString(fmt.Sprintf("%s %s %s at %s\n", comment, ProvenanceHeader, options.Version, time.Now().UTC().Format(time.RFC3339)))
+	}
+}
+
+func writeProvenanceComment(sb *strings.Builder, options RenderOptions, comment string, group EntryGroup) {
+	if options.Provenance {
+		sb.WriteString(comment)
+		sb.WriteString(" ")
+		sb.WriteString(strings.Join(group.ObjectIds, ", "))
+		sb.WriteString("\n")
+	}
+}
+
+func uniqueSorted(values []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,89 @@
+package hostsfile
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func render(t *testing.T, r Renderer, snapshot Snapshot) string {
+	rv, err := r.Render(snapshot)
+	assert.Nil(t, err)
+
+	return rv
+}
+
+func testSnapshot() Snapshot {
+	hf := NewHostsFile()
+	hf.SetHostsEntries("abc", []HostsEntry{
+		HostsEntry{"192.168.1.2", []string{"google.com", "www.google.com"}},
+		HostsEntry{"fd00::2", []string{"google.com"}},
+	})
+	hf.SetHostsEntry("xyz", HostsEntry{"192.168.1.2", []string{"google.com", "*.apps.google.com"}})
+
+	return hf.Snapshot()
+}
+
+func TestNewRenderer(t *testing.T) {
+	for _, format := range RendererFormats {
+		if format == "template" || format == "zone" || format == "reverse-zone" {
+			continue
+		}
+
+		r, err := NewRenderer(format, RenderOptions{})
+		assert.Nil(t, err)
+		assert.NotNil(t, r)
+	}
+
+	_, err := NewRenderer("template", RenderOptions{})
+	assert.Error(t, err)
+
+	r, err := NewRenderer("template", RenderOptions{Template: "{{ len .Groups }}"})
+	assert.Nil(t, err)
+	assert.NotNil(t, r)
+
+	_, err = NewRenderer("bind", RenderOptions{})
+	assert.Equal(t, "unknown format \"bind\", must be one of hosts, dnsmasq-address, dnsmasq-host-record, unbound, json, template, zone, reverse-zone", err.Error())
+}
+
+func TestRenderersSkipRepeatedRecords(t *testing.T) {
+	r, err := NewRenderer("dnsmasq-host-record", RenderOptions{Provenance: true})
+	assert.Nil(t, err)
+
+	rv, err := r.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"# abc",
+		"host-record=google.com,192.168.1.2",
+		"host-record=www.google.com,192.168.1.2",
+		"host-record=google.com,fd00::2",
+		"# xyz",
+		"address=/apps.google.com/192.168.1.2",
+	}, strings.Split(strings.TrimSuffix(rv, "\n"), "\n")[1:])
+}
+
+func testRecordsSnapshot() Snapshot {
+	hf := NewHostsFile()
+	hf.SetHostsEntry("abc", HostsEntry{"192.168.1.2", []string{"google.com"}})
+	hf.SetRecords("abc", []Record{
+		*NewCNAMERecord("www.google.com.", "google.com."),
+		*NewSRVRecord("_http._tcp.google.com.", 0, 5, 80, "google.com."),
+	})
+	hf.SetRecords("xyz", []Record{
+		*NewCNAMERecord("www.google.com", "google.com"),
+		*NewTXTRecord("google.com", []string{"v=spf1 -all"}),
+	})
+
+	return hf.Snapshot()
+}
+
+func TestHostsRendererSkipsRecords(t *testing.T) {
+	hr := HostsRenderer{RenderOptions{}}
+
+	rv, err := hr.Render(testRecordsSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.1.2\tgoogle.com\n", rv)
+}
@@ -0,0 +1,56 @@
+package hostsfile
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Renders a user supplied text/template.
+// The template is executed with a TemplateData, and can use the helpers in
+// TemplateFuncs.
+type TemplateRenderer struct {
+	options  RenderOptions
+	template *template.Template
+}
+
+type TemplateData struct {
+	Groups      []EntryGroup
+	Version     string
+	GeneratedAt time.Time
+}
+
+var TemplateFuncs template.FuncMap = template.FuncMap{
+	"join":       strings.Join,
+	"recordType": addressRecordType,
+	"isWildcard": IsWildcardHostname,
+	"fqdn":       fqdn,
+	"bare": func(hostname string) string {
+		return strings.TrimSuffix(hostname, ".")
+	},
+}
+
+func NewTemplateRenderer(options RenderOptions) (*TemplateRenderer, error) {
+	if options.Template == "" {
+		return nil, errors.New("template format requires a template")
+	}
+
+	t, err := template.New("hostsfile").Funcs(TemplateFuncs).Parse(options.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateRenderer{options, t}, nil
+}
+
+func (tr *TemplateRenderer) Render(snapshot Snapshot) (string, error) {
+	var sb strings.Builder
+
+	data := TemplateData{snapshot.Groups, tr.options.Version, time.Now().UTC()}
+	if err := tr.template.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
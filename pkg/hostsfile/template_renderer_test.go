@@ -0,0 +1,31 @@
+package hostsfile
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	template := `{{ range .Groups }}{{ range .Entries }}{{ $ip := .Ip }}{{ range .Hosts }}{{ if not (isWildcard .) }}{{ fqdn . }} {{ recordType $ip }} {{ $ip }}
+{{ end }}{{ end }}{{ end }}{{ end }}`
+	tr, err := NewTemplateRenderer(RenderOptions{Template: template})
+	assert.Nil(t, err)
+
+	rv, err := tr.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "google.com. A 192.168.1.2\nwww.google.com. A 192.168.1.2\ngoogle.com. AAAA fd00::2\n", rv)
+}
+
+func TestTemplateRendererInvalid(t *testing.T) {
+	_, err := NewTemplateRenderer(RenderOptions{Template: "{{ .Groups "})
+	assert.Error(t, err)
+
+	tr, err := NewTemplateRenderer(RenderOptions{Template: "{{ .Missing }}"})
+	assert.Nil(t, err)
+
+	_, err = tr.Render(testSnapshot())
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package hostsfile
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Unbound local-data statements, to be included in a server: clause.
+// Wildcards become redirect zones, which also answer for the zone's own name.
+// TXT data holds double quotes, so those lines are single quoted instead, and
+// any single quotes in the text are written as \039 escapes.
+type UnboundRenderer struct {
+	options RenderOptions
+}
+
+func (ur *UnboundRenderer) Render(snapshot Snapshot) (string, error) {
+	var sb strings.Builder
+
+	writeProvenanceHeader(&sb, ur.options, "#")
+
+	groups, records := hostnameRecords(snapshot.Groups)
+	for i, group := range groups {
+		writeProvenanceComment(&sb, ur.options, "#", group)
+
+		for _, record := range records[i] {
+			name := record.hostname + "."
+			if IsWildcardHostname(record.hostname) {
+				name = strings.TrimPrefix(name, "*.")
+				sb.WriteString(fmt.Sprintf("local-zone: \"%s\" redirect\n", name))
+			}
+
+			sb.WriteString(fmt.Sprintf("local-data: \"%s IN %s %s\"\n", name, addressRecordType(record.ip), record.ip))
+		}
+
+		for _, record := range group.Records {
+			data := fmt.Sprintf("%s IN %s %s", fqdn(normalizeHostname(record.name)), record.rtype, record.Data())
+			if record.rtype == RecordTXT {
+				sb.WriteString(fmt.Sprintf("local-data: '%s'\n", strings.ReplaceAll(data, "'", "\\039")))
+			} else {
+				sb.WriteString(fmt.Sprintf("local-data: \"%s\"\n", data))
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func addressRecordType(ip string) string {
+	if parsed := net.ParseIP(stripZone(ip)); parsed != nil && parsed.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
@@ -0,0 +1,51 @@
+package hostsfile
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnboundRenderer(t *testing.T) {
+	ur := UnboundRenderer{RenderOptions{}}
+
+	rv, err := ur.Render(testSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "local-data: \"google.com. IN A 192.168.1.2\"\n"+
+		"local-data: \"www.google.com. IN A 192.168.1.2\"\n"+
+		"local-data: \"google.com. IN AAAA fd00::2\"\n"+
+		"local-zone: \"apps.google.com.\" redirect\n"+
+		"local-data: \"apps.google.com. IN A 192.168.1.2\"\n", rv)
+}
+
+func TestAddressRecordType(t *testing.T) {
+	assert.Equal(t, "A", addressRecordType("192.168.1.2"))
+	assert.Equal(t, "AAAA", addressRecordType("fd00::2"))
+	assert.Equal(t, "AAAA", addressRecordType("fe80::1%eth0"))
+}
+
+func TestUnboundRendererRecords(t *testing.T) {
+	ur := UnboundRenderer{RenderOptions{}}
+
+	rv, err := ur.Render(testRecordsSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "local-data: \"google.com. IN A 192.168.1.2\"\n"+
+		"local-data: \"www.google.com. IN CNAME google.com.\"\n"+
+		"local-data: \"_http._tcp.google.com. IN SRV 0 5 80 google.com.\"\n"+
+		"local-data: 'google.com. IN TXT \"v=spf1 -all\"'\n", rv)
+}
+
+func TestUnboundRendererTXTQuotes(t *testing.T) {
+	ur := UnboundRenderer{RenderOptions{}}
+
+	hf := NewHostsFile()
+	hf.SetRecords("abc", []Record{
+		*NewTXTRecord("google.com", []string{"it's \"quoted\""}),
+	})
+
+	rv, err := ur.Render(hf.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, "local-data: 'google.com. IN TXT \"it\\039s \\\"quoted\\\"\"'\n", rv)
+}
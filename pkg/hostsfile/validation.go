@@ -0,0 +1,224 @@
+package hostsfile
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// What to do with hostnames like *.example.com.
+// Hosts format output can never express them, so they're always left out of
+// it, but allowing them keeps them in the model for outputs that can.
+type WildcardPolicy int
+
+const (
+	WildcardSkip WildcardPolicy = iota
+	WildcardAllow
+)
+
+func ParseWildcardPolicy(s string) (WildcardPolicy, error) {
+	switch s {
+	case "skip":
+		return WildcardSkip, nil
+	case "allow":
+		return WildcardAllow, nil
+	}
+
+	return WildcardSkip, fmt.Errorf("unknown wildcard policy %q", s)
+}
+
+// Accepts IPv4 and IPv6 addresses, including IPv6 addresses with a zone.
+func ValidateIp(ip string) error {
+	if net.ParseIP(strings.SplitN(ip, "%", 2)[0]) == nil {
+		return fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	return nil
+}
+
+// RFC 1123 hostnames, optionally fully qualified with a trailing dot.
+func ValidateHostname(hostname string) error {
+	name := strings.TrimSuffix(hostname, ".")
+	if name == "" {
+		return fmt.Errorf("invalid hostname %q: hostname is empty", hostname)
+	}
+
+	if len(name) > 253 {
+		return fmt.Errorf("invalid hostname %q: hostname is longer than 253 characters", hostname)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if err := validateLabel(label); err != nil {
+			return fmt.Errorf("invalid hostname %q: %s", hostname, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func IsWildcardHostname(hostname string) bool {
+	return strings.HasPrefix(hostname, "*.")
+}
+
+func validateLabel(label string) error {
+	if label == "" {
+		return errors.New("empty label")
+	}
+
+	if len(label) > 63 {
+		return fmt.Errorf("label %q is longer than 63 characters", label)
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q starts or ends with a hyphen", label)
+	}
+
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' {
+			return fmt.Errorf("label %q contains %q", label, c)
+		}
+	}
+
+	return nil
+}
+
+// Returns a copy of the entry holding only the hostnames that can be
+// published, along with the reasons for anything that was left out.
+// If the IP is invalid, or no hostnames are left, no entry is returned.
+func (he *HostsEntry) Validate(policy WildcardPolicy) (*HostsEntry, []error) {
+	if err := ValidateIp(he.ip); err != nil {
+		return nil, []error{err}
+	}
+
+	errs := []error{}
+	hosts := []string{}
+	for _, host := range he.hosts {
+		name := host
+		if IsWildcardHostname(host) {
+			if policy == WildcardSkip {
+				errs = append(errs, fmt.Errorf("wildcard hostname %q not allowed", host))
+				continue
+			}
+			name = strings.TrimPrefix(host, "*.")
+		}
+
+		if err := ValidateHostname(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if len(hosts) == 0 {
+		return nil, append(errs, fmt.Errorf("no valid hostnames for %s", he.ip))
+	}
+
+	return NewHostsEntry(he.ip, hosts), errs
+}
+
+// Validates each entry in turn, dropping any that can't be published.
+func ValidateHostsEntries(entries []HostsEntry, policy WildcardPolicy) ([]HostsEntry, []error) {
+	errs := []error{}
+	valid := []HostsEntry{}
+	for _, entry := range entries {
+		he, entryErrs := entry.Validate(policy)
+		errs = append(errs, entryErrs...)
+		if he != nil {
+			valid = append(valid, *he)
+		}
+	}
+
+	return valid, errs
+}
+
+// Like hostnames, except that labels may start with an underscore, as the
+// service and protocol labels of SRV records and names like _acme-challenge
+// do.
+func ValidateRecordName(name string) error {
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return fmt.Errorf("invalid record name %q: name is empty", name)
+	}
+
+	if len(trimmed) > 253 {
+		return fmt.Errorf("invalid record name %q: name is longer than 253 characters", name)
+	}
+
+	for _, label := range strings.Split(trimmed, ".") {
+		if err := validateLabel(strings.TrimPrefix(label, "_")); err != nil {
+			return fmt.Errorf("invalid record name %q: %s", name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Returns the record if it can be published, or the reason it can't.
+// SRV names must start with service and protocol labels, like
+// _http._tcp.example.com, and each TXT string is limited to 255 bytes.
+func (r *Record) Validate(policy WildcardPolicy) (*Record, error) {
+	name := r.name
+	if IsWildcardHostname(name) {
+		if policy == WildcardSkip {
+			return nil, fmt.Errorf("wildcard record name %q not allowed", r.name)
+		}
+		name = strings.TrimPrefix(name, "*.")
+	}
+
+	if err := ValidateRecordName(name); err != nil {
+		return nil, err
+	}
+
+	switch r.rtype {
+	case RecordCNAME:
+		if err := ValidateHostname(r.target); err != nil {
+			return nil, fmt.Errorf("invalid CNAME target for %s: %s", r.name, err.Error())
+		}
+
+		if normalizeHostname(r.target) == normalizeHostname(r.name) {
+			return nil, fmt.Errorf("CNAME %s points to itself", r.name)
+		}
+	case RecordSRV:
+		labels := strings.Split(name, ".")
+		if len(labels) < 3 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			return nil, fmt.Errorf("invalid SRV name %q: must start with service and protocol labels", r.name)
+		}
+
+		if err := ValidateHostname(r.target); err != nil {
+			return nil, fmt.Errorf("invalid SRV target for %s: %s", r.name, err.Error())
+		}
+	case RecordTXT:
+		if len(r.text) == 0 {
+			return nil, fmt.Errorf("TXT record %s has no text", r.name)
+		}
+
+		for _, text := range r.text {
+			if len(text) > 255 {
+				return nil, fmt.Errorf("TXT record %s has a string longer than 255 bytes", r.name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type %q for %s", r.rtype, r.name)
+	}
+
+	rv := *r
+	return &rv, nil
+}
+
+// Validates each record in turn, dropping any that can't be published.
+func ValidateRecords(records []Record, policy WildcardPolicy) ([]Record, []error) {
+	errs := []error{}
+	valid := []Record{}
+	for _, record := range records {
+		r, err := record.Validate(policy)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid = append(valid, *r)
+	}
+
+	return valid, errs
+}
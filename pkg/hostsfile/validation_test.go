@@ -0,0 +1,153 @@
+package hostsfile
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWildcardPolicy(t *testing.T) {
+	policy, err := ParseWildcardPolicy("skip")
+	assert.NoError(t, err)
+	assert.Equal(t, WildcardSkip, policy)
+
+	policy, err = ParseWildcardPolicy("allow")
+	assert.NoError(t, err)
+	assert.Equal(t, WildcardAllow, policy)
+
+	_, err = ParseWildcardPolicy("sometimes")
+	assert.EqualError(t, err, "unknown wildcard policy \"sometimes\"")
+}
+
+func TestValidateIp(t *testing.T) {
+	assert.NoError(t, ValidateIp("192.168.1.2"))
+	assert.NoError(t, ValidateIp("fd00::2"))
+	assert.NoError(t, ValidateIp("fe80::1%eth0"))
+	assert.EqualError(t, ValidateIp(""), "invalid IP address \"\"")
+	assert.EqualError(t, ValidateIp("192.168.1"), "invalid IP address \"192.168.1\"")
+	assert.EqualError(t, ValidateIp("google.com"), "invalid IP address \"google.com\"")
+}
+
+func TestValidateHostname(t *testing.T) {
+	var tests = []struct {
+		name     string
+		hostname string
+		err      string
+	}{
+		{"Bare", "google", ""},
+		{"Qualified", "www.google.com", ""},
+		{"Fully Qualified", "www.google.com.", ""},
+		{"Digits And Hyphens", "1-2-3.example.com", ""},
+		{"Empty", "", "invalid hostname \"\": hostname is empty"},
+		{"Only Dot", ".", "invalid hostname \".\": hostname is empty"},
+		{"Empty Label", "www..google.com", "invalid hostname \"www..google.com\": empty label"},
+		{"Leading Hyphen", "-www.google.com", "invalid hostname \"-www.google.com\": label \"-www\" starts or ends with a hyphen"},
+		{"Trailing Hyphen", "www-.google.com", "invalid hostname \"www-.google.com\": label \"www-\" starts or ends with a hyphen"},
+		{"Underscore", "some_host.google.com", "invalid hostname \"some_host.google.com\": label \"some_host\" contains '_'"},
+		{"Wildcard", "*.google.com", "invalid hostname \"*.google.com\": label \"*\" contains '*'"},
+		{"Long Label", strings.Repeat("a", 64) + ".com", "invalid hostname \"" + strings.Repeat("a", 64) + ".com\": label \"" + strings.Repeat("a", 64) + "\" is longer than 63 characters"},
+		{"Long Hostname", strings.Repeat("a.", 127) + "a", "invalid hostname \"" + strings.Repeat("a.", 127) + "a\": hostname is longer than 253 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHostname(tt.hostname)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHostsEntryValidate(t *testing.T) {
+	he := HostsEntry{"192.168.1.2", []string{"google.com", "", "*.google.com", "bad_name.com"}}
+
+	valid, errs := he.Validate(WildcardSkip)
+	assert.Equal(t, &HostsEntry{"192.168.1.2", []string{"google.com"}}, valid)
+	assert.Equal(t, 3, len(errs))
+	assert.EqualError(t, errs[0], "invalid hostname \"\": hostname is empty")
+	assert.EqualError(t, errs[1], "wildcard hostname \"*.google.com\" not allowed")
+
+	valid, errs = he.Validate(WildcardAllow)
+	assert.Equal(t, &HostsEntry{"192.168.1.2", []string{"google.com", "*.google.com"}}, valid)
+	assert.Equal(t, 2, len(errs))
+}
+
+func TestHostsEntryValidateRejected(t *testing.T) {
+	he := HostsEntry{"192.168.1", []string{"google.com"}}
+
+	valid, errs := he.Validate(WildcardSkip)
+	assert.Nil(t, valid)
+	assert.Equal(t, 1, len(errs))
+	assert.EqualError(t, errs[0], "invalid IP address \"192.168.1\"")
+
+	he = HostsEntry{"192.168.1.2", []string{""}}
+
+	valid, errs = he.Validate(WildcardSkip)
+	assert.Nil(t, valid)
+	assert.Equal(t, 2, len(errs))
+	assert.EqualError(t, errs[1], "no valid hostnames for 192.168.1.2")
+}
+
+func TestValidateHostsEntries(t *testing.T) {
+	he1 := HostsEntry{"192.168.1.2", []string{"google.com"}}
+	he2 := HostsEntry{"192.168.1", []string{"google.com"}}
+	he3 := HostsEntry{"fd00::2", []string{"google.com"}}
+
+	valid, errs := ValidateHostsEntries([]HostsEntry{he1, he2, he3}, WildcardSkip)
+	assert.Equal(t, []HostsEntry{he1, he3}, valid)
+	assert.Equal(t, 1, len(errs))
+}
+
+func TestValidateRecordName(t *testing.T) {
+	assert.Nil(t, ValidateRecordName("_http._tcp.google.com."))
+	assert.Nil(t, ValidateRecordName("_acme-challenge.google.com"))
+	assert.Equal(t, "invalid record name \"_.google.com\": empty label", ValidateRecordName("_.google.com").Error())
+	assert.Equal(t, "invalid record name \"\": name is empty", ValidateRecordName("").Error())
+}
+
+func TestRecordValidate(t *testing.T) {
+	r, err := NewCNAMERecord("www.google.com", "google.com").Validate(WildcardSkip)
+	assert.Nil(t, err)
+	assert.Equal(t, NewCNAMERecord("www.google.com", "google.com"), r)
+
+	_, err = NewCNAMERecord("*.google.com", "google.com").Validate(WildcardSkip)
+	assert.Equal(t, "wildcard record name \"*.google.com\" not allowed", err.Error())
+
+	_, err = NewCNAMERecord("*.google.com", "google.com").Validate(WildcardAllow)
+	assert.Nil(t, err)
+
+	_, err = NewCNAMERecord("www.google.com", "www.google.com.").Validate(WildcardSkip)
+	assert.Equal(t, "CNAME www.google.com points to itself", err.Error())
+
+	_, err = NewCNAMERecord("www.google.com", "_google.com").Validate(WildcardSkip)
+	assert.Equal(t, "invalid CNAME target for www.google.com: invalid hostname \"_google.com\": label \"_google\" contains '_'", err.Error())
+
+	_, err = NewSRVRecord("_http._tcp.google.com", 0, 0, 80, "google.com").Validate(WildcardSkip)
+	assert.Nil(t, err)
+
+	_, err = NewSRVRecord("http.google.com", 0, 0, 80, "google.com").Validate(WildcardSkip)
+	assert.Equal(t, "invalid SRV name \"http.google.com\": must start with service and protocol labels", err.Error())
+
+	_, err = NewTXTRecord("google.com", []string{}).Validate(WildcardSkip)
+	assert.Equal(t, "TXT record google.com has no text", err.Error())
+
+	_, err = NewTXTRecord("google.com", []string{strings.Repeat("a", 256)}).Validate(WildcardSkip)
+	assert.Equal(t, "TXT record google.com has a string longer than 255 bytes", err.Error())
+
+	_, err = (&Record{name: "google.com", rtype: "MX"}).Validate(WildcardSkip)
+	assert.Equal(t, "unsupported record type \"MX\" for google.com", err.Error())
+}
+
+func TestValidateRecords(t *testing.T) {
+	r1 := NewCNAMERecord("www.google.com", "google.com")
+	r2 := NewTXTRecord("google.com", []string{})
+
+	valid, errs := ValidateRecords([]Record{*r1, *r2}, WildcardSkip)
+	assert.Equal(t, []Record{*r1}, valid)
+	assert.Equal(t, 1, len(errs))
+}
@@ -0,0 +1,278 @@
+package hostsfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What goes into the SOA and NS records of a zone file.
+// Origin is the zone's domain, and hostnames outside of it are left out.
+// ReverseOrigin is the in-addr.arpa or ip6.arpa domain of the reverse zone,
+// and addresses outside of it are left out.
+// Hostmaster can be given as an email address, or already in zone file form.
+// Zero timers are given defaults. The negative caching TTL defaults to the
+// record TTL.
+// The serial is kept in SerialFile, if given, so that it keeps increasing
+// across restarts.
+type ZoneOptions struct {
+	Origin        string
+	ReverseOrigin string
+	Nameservers   []string
+	Hostmaster    string
+
+	Ttl     uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+
+	SerialFile string
+}
+
+// An RFC 1035 zone file for a single zone.
+// Forward zones hold address, CNAME, SRV and TXT records, and reverse zones
+// hold a PTR record for each address, pointing at its canonical hostname.
+// The serial only changes when the records do, and never decreases; it's
+// bumped to the current Unix time if that's higher, so a lost serial file
+// still produces a serial that secondaries will accept.
+type ZoneRenderer struct {
+	options RenderOptions
+	zone    ZoneOptions
+	reverse bool
+
+	lock   *sync.Mutex
+	serial uint32
+	hash   string
+}
+
+func NewZoneRenderer(options RenderOptions) (*ZoneRenderer, error) {
+	return newZoneRenderer(options, false)
+}
+
+func NewReverseZoneRenderer(options RenderOptions) (*ZoneRenderer, error) {
+	return newZoneRenderer(options, true)
+}
+
+func newZoneRenderer(options RenderOptions, reverse bool) (*ZoneRenderer, error) {
+	zone := options.Zone
+	zone.Origin = strings.ToLower(strings.TrimSuffix(zone.Origin, "."))
+	if zone.Origin == "" {
+		return nil, errors.New("zone format requires an origin")
+	}
+
+	if len(zone.Nameservers) == 0 {
+		return nil, errors.New("zone format requires at least one nameserver")
+	}
+
+	if zone.Hostmaster == "" {
+		zone.Hostmaster = "hostmaster." + zone.Origin
+	}
+	zone.Hostmaster = strings.Replace(zone.Hostmaster, "@", ".", 1)
+
+	if reverse {
+		zone.Origin = strings.ToLower(strings.TrimSuffix(zone.ReverseOrigin, "."))
+		if !inDomain(zone.Origin, "in-addr.arpa") && !inDomain(zone.Origin, "ip6.arpa") {
+			return nil, errors.New("reverse zone format requires an in-addr.arpa or ip6.arpa origin")
+		}
+	}
+
+	if zone.Ttl == 0 {
+		zone.Ttl = 300
+	}
+	if zone.Refresh == 0 {
+		zone.Refresh = 3600
+	}
+	if zone.Retry == 0 {
+		zone.Retry = 600
+	}
+	if zone.Expire == 0 {
+		zone.Expire = 604800
+	}
+	if zone.Minimum == 0 {
+		zone.Minimum = zone.Ttl
+	}
+
+	zr := ZoneRenderer{options, zone, reverse, &sync.Mutex{}, 0, ""}
+	if err := zr.readSerial(); err != nil {
+		return nil, err
+	}
+
+	return &zr, nil
+}
+
+func (zr *ZoneRenderer) Render(snapshot Snapshot) (string, error) {
+	var records strings.Builder
+	if zr.reverse {
+		zr.writePtrRecords(&records, snapshot)
+	} else {
+		zr.writeAddressRecords(&records, snapshot)
+	}
+
+	serial, err := zr.nextSerial(records.String())
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	writeProvenanceHeader(&sb, zr.options, ";")
+	sb.WriteString(fmt.Sprintf("$ORIGIN %s.\n", zr.zone.Origin))
+	sb.WriteString(fmt.Sprintf("$TTL %d\n", zr.zone.Ttl))
+	sb.WriteString(fmt.Sprintf("@\tIN\tSOA\t%s %s %d %d %d %d %d\n", fqdn(zr.zone.Nameservers[0]), fqdn(zr.zone.Hostmaster), serial, zr.zone.Refresh, zr.zone.Retry, zr.zone.Expire, zr.zone.Minimum))
+	for _, nameserver := range zr.zone.Nameservers {
+		sb.WriteString(fmt.Sprintf("@\tIN\tNS\t%s\n", fqdn(nameserver)))
+	}
+	sb.WriteString(records.String())
+
+	return sb.String(), nil
+}
+
+func (zr *ZoneRenderer) writeAddressRecords(sb *strings.Builder, snapshot Snapshot) {
+	groups, hostnameRecords := hostnameRecords(snapshot.Groups)
+	for i, group := range groups {
+		groupRecords := []hostnameRecord{}
+		for _, record := range hostnameRecords[i] {
+			if zr.inZone(record.hostname) {
+				groupRecords = append(groupRecords, record)
+			}
+		}
+
+		otherRecords := []Record{}
+		for _, record := range group.Records {
+			if zr.inZone(normalizeHostname(record.name)) {
+				otherRecords = append(otherRecords, record)
+			}
+		}
+
+		if len(groupRecords) == 0 && len(otherRecords) == 0 {
+			continue
+		}
+
+		writeProvenanceComment(sb, zr.options, ";", group)
+		for _, record := range groupRecords {
+			sb.WriteString(fmt.Sprintf("%s.\tIN\t%s\t%s\n", record.hostname, addressRecordType(record.ip), record.ip))
+		}
+		for _, record := range otherRecords {
+			sb.WriteString(fmt.Sprintf("%s.\tIN\t%s\t%s\n", normalizeHostname(record.name), record.rtype, record.Data()))
+		}
+	}
+}
+
+func (zr *ZoneRenderer) writePtrRecords(sb *strings.Builder, snapshot Snapshot) {
+	for _, record := range ptrRecords(snapshot.Groups) {
+		name, err := reverseName(record.ip)
+		if err != nil || !zr.inZone(name) {
+			continue
+		}
+
+		writeProvenanceComment(sb, zr.options, ";", EntryGroup{ObjectIds: record.objectIds})
+		sb.WriteString(fmt.Sprintf("%s.\tIN\tPTR\t%s.\n", name, record.hostname))
+	}
+}
+
+func (zr *ZoneRenderer) Serial() uint32 {
+	zr.lock.Lock()
+	defer zr.lock.Unlock()
+
+	return zr.serial
+}
+
+func (zr *ZoneRenderer) inZone(hostname string) bool {
+	return inDomain(strings.TrimPrefix(hostname, "*."), zr.zone.Origin)
+}
+
+func inDomain(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// Provenance comments are part of the hashed records, so a record moving to
+// another object changes the serial too.
+func (zr *ZoneRenderer) nextSerial(records string) (uint32, error) {
+	zr.lock.Lock()
+	defer zr.lock.Unlock()
+
+	sum := sha256.Sum256([]byte(records))
+	hash := hex.EncodeToString(sum[:])
+	if hash == zr.hash {
+		return zr.serial, nil
+	}
+
+	serial := zr.serial + 1
+	if now := uint32(time.Now().Unix()); now > serial {
+		serial = now
+	}
+
+	if err := zr.writeSerial(serial, hash); err != nil {
+		return 0, err
+	}
+
+	zr.serial = serial
+	zr.hash = hash
+	return serial, nil
+}
+
+// The serial file holds the serial, and the hash of the records it was given
+// to.
+func (zr *ZoneRenderer) readSerial() error {
+	if zr.zone.SerialFile == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(zr.zone.SerialFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(contents))
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid serial file %s", zr.zone.SerialFile)
+	}
+
+	serial, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid serial file %s: %s", zr.zone.SerialFile, err.Error())
+	}
+
+	zr.serial = uint32(serial)
+	zr.hash = fields[1]
+	return nil
+}
+
+// Written to a temporary file and renamed over the old one, so a crash never
+// leaves a truncated serial behind.
+func (zr *ZoneRenderer) writeSerial(serial uint32, hash string) error {
+	if zr.zone.SerialFile == "" {
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(zr.zone.SerialFile), ".serial")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := fmt.Fprintf(f, "%d %s\n", serial, hash); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), zr.zone.SerialFile)
+}
+
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
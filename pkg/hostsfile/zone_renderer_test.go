@@ -0,0 +1,141 @@
+package hostsfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func testZoneSnapshot() Snapshot {
+	hf := NewHostsFile()
+	hf.SetHostsEntries("abc", []HostsEntry{
+		HostsEntry{"192.168.1.2", []string{"some-service.internal.aleemhaji.com.", "google.com"}},
+		HostsEntry{"fd00::2", []string{"some-service.internal.aleemhaji.com."}},
+	})
+	hf.SetHostsEntry("xyz", HostsEntry{"192.168.1.3", []string{"*.apps.internal.aleemhaji.com"}})
+
+	return hf.Snapshot()
+}
+
+func TestNewZoneRenderer(t *testing.T) {
+	_, err := NewZoneRenderer(RenderOptions{})
+	assert.Equal(t, "zone format requires an origin", err.Error())
+
+	_, err = NewZoneRenderer(RenderOptions{Zone: ZoneOptions{Origin: "internal.aleemhaji.com"}})
+	assert.Equal(t, "zone format requires at least one nameserver", err.Error())
+
+	zr, err := NewZoneRenderer(RenderOptions{Zone: ZoneOptions{Origin: "Internal.aleemhaji.com.", Nameservers: []string{"ns1.internal.aleemhaji.com"}, Hostmaster: "admin@aleemhaji.com"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "internal.aleemhaji.com", zr.zone.Origin)
+	assert.Equal(t, "admin.aleemhaji.com", zr.zone.Hostmaster)
+	assert.Equal(t, uint32(300), zr.zone.Minimum)
+}
+
+func TestZoneRenderer(t *testing.T) {
+	zr, err := NewZoneRenderer(RenderOptions{Zone: ZoneOptions{Origin: "internal.aleemhaji.com", Nameservers: []string{"ns1.internal.aleemhaji.com", "ns2.internal.aleemhaji.com."}}})
+	assert.Nil(t, err)
+
+	rv, err := zr.Render(testZoneSnapshot())
+	assert.Nil(t, err)
+
+	lines := strings.Split(rv, "\n")
+	assert.Equal(t, "$ORIGIN internal.aleemhaji.com.", lines[0])
+	assert.Equal(t, "$TTL 300", lines[1])
+	assert.Equal(t, fmt.Sprintf("@\tIN\tSOA\tns1.internal.aleemhaji.com. hostmaster.internal.aleemhaji.com. %d 3600 600 604800 300", zr.Serial()), lines[2])
+	assert.Equal(t, []string{
+		"@\tIN\tNS\tns1.internal.aleemhaji.com.",
+		"@\tIN\tNS\tns2.internal.aleemhaji.com.",
+		"some-service.internal.aleemhaji.com.\tIN\tA\t192.168.1.2",
+		"some-service.internal.aleemhaji.com.\tIN\tAAAA\tfd00::2",
+		"*.apps.internal.aleemhaji.com.\tIN\tA\t192.168.1.3",
+		"",
+	}, lines[3:])
+}
+
+func TestZoneRendererSerial(t *testing.T) {
+	// Start ahead of the clock, so it doesn't move the serial mid-test.
+	serialFile := filepath.Join(t.TempDir(), "serial")
+	assert.Nil(t, os.WriteFile(serialFile, []byte("4000000000 abc\n"), 0644))
+	options := RenderOptions{Zone: ZoneOptions{Origin: "internal.aleemhaji.com", Nameservers: []string{"ns1.internal.aleemhaji.com"}, SerialFile: serialFile}}
+
+	zr, err := NewZoneRenderer(options)
+	assert.Nil(t, err)
+
+	_, err = zr.Render(testZoneSnapshot())
+	assert.Nil(t, err)
+	serial := zr.Serial()
+	assert.Equal(t, uint32(4000000001), serial)
+
+	// Unchanged records keep the serial.
+	_, err = zr.Render(testZoneSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, serial, zr.Serial())
+
+	_, err = zr.Render(Snapshot{})
+	assert.Nil(t, err)
+	assert.Equal(t, serial+1, zr.Serial())
+
+	// A restart picks up where the last one left off.
+	zr, err = NewZoneRenderer(options)
+	assert.Nil(t, err)
+	assert.Equal(t, serial+1, zr.Serial())
+
+	_, err = zr.Render(Snapshot{})
+	assert.Nil(t, err)
+	assert.Equal(t, serial+1, zr.Serial())
+
+	_, err = zr.Render(testZoneSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, serial+2, zr.Serial())
+}
+
+func TestZoneRendererInvalidSerialFile(t *testing.T) {
+	serialFile := filepath.Join(t.TempDir(), "serial")
+	assert.Nil(t, os.WriteFile(serialFile, []byte("abc\n"), 0644))
+
+	_, err := NewZoneRenderer(RenderOptions{Zone: ZoneOptions{Origin: "internal.aleemhaji.com", Nameservers: []string{"ns1.internal.aleemhaji.com"}, SerialFile: serialFile}})
+	assert.Error(t, err)
+}
+
+func TestReverseZoneRenderer(t *testing.T) {
+	options := RenderOptions{Zone: ZoneOptions{Origin: "internal.aleemhaji.com", Nameservers: []string{"ns1.internal.aleemhaji.com"}}}
+	_, err := NewReverseZoneRenderer(options)
+	assert.Equal(t, "reverse zone format requires an in-addr.arpa or ip6.arpa origin", err.Error())
+
+	options.Zone.ReverseOrigin = "1.168.192.in-addr.arpa."
+	zr, err := NewReverseZoneRenderer(options)
+	assert.Nil(t, err)
+
+	rv, err := zr.Render(testZoneSnapshot())
+	assert.Nil(t, err)
+
+	lines := strings.Split(rv, "\n")
+	assert.Equal(t, "$ORIGIN 1.168.192.in-addr.arpa.", lines[0])
+	assert.Equal(t, fmt.Sprintf("@\tIN\tSOA\tns1.internal.aleemhaji.com. hostmaster.internal.aleemhaji.com. %d 3600 600 604800 300", zr.Serial()), lines[2])
+	assert.Equal(t, []string{
+		"@\tIN\tNS\tns1.internal.aleemhaji.com.",
+		"2.1.168.192.in-addr.arpa.\tIN\tPTR\tgoogle.com.",
+		"",
+	}, lines[3:])
+}
+
+func TestZoneRendererRecords(t *testing.T) {
+	zr, err := NewZoneRenderer(RenderOptions{Zone: ZoneOptions{Origin: "google.com", Nameservers: []string{"ns1.google.com"}}})
+	assert.Nil(t, err)
+
+	rv, err := zr.Render(testRecordsSnapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"google.com.\tIN\tA\t192.168.1.2",
+		"www.google.com.\tIN\tCNAME\tgoogle.com.",
+		"_http._tcp.google.com.\tIN\tSRV\t0 5 80 google.com.",
+		"google.com.\tIN\tTXT\t\"v=spf1 -all\"",
+		"",
+	}, strings.Split(rv, "\n")[4:])
+}